@@ -0,0 +1,143 @@
+package gnAsteroid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gotuna/gotuna"
+)
+
+// Assets are the files of an asteroid which are served as is, next to its
+// markdown pages: images, documents, media, fonts, etc.
+// Only the extensions of Asteroid.AssetExtensions are served, so that e.g.
+// keys or scripts lying in the asteroid directory are not; dotfiles never are.
+
+// DefaultAssetExtensions are served when Asteroid.AssetExtensions is nil.
+var DefaultAssetExtensions = []string{
+	// images
+	".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".avif", ".ico",
+	// documents
+	".pdf", ".txt", ".csv", ".json", ".zip",
+	// media
+	".mp3", ".ogg", ".wav", ".mp4", ".webm",
+	// fonts
+	".woff", ".woff2", ".ttf", ".otf",
+}
+
+// assetTypes are the MIME types of asset extensions which may be missing from
+// the system's (see mime.TypeByExtension), e.g. on minimal containers.
+var assetTypes = map[string]string{
+	".avif":  "image/avif",
+	".csv":   "text/csv; charset=utf-8",
+	".ico":   "image/x-icon",
+	".mp3":   "audio/mpeg",
+	".mp4":   "video/mp4",
+	".ogg":   "audio/ogg",
+	".otf":   "font/otf",
+	".svg":   "image/svg+xml",
+	".ttf":   "font/ttf",
+	".txt":   "text/plain; charset=utf-8",
+	".wav":   "audio/wav",
+	".webm":  "video/webm",
+	".webp":  "image/webp",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".zip":   "application/zip",
+}
+
+// assetType returns the MIME type of name, "" if unknown.
+func assetType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := assetTypes[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// normalizeExtension returns ext lower-cased, with a leading dot, e.g. "PDF" -> ".pdf".
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// hasExtension tells whether name has one of the extensions exts.
+func hasExtension(name string, exts []string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, x := range exts {
+		if ext != "" && normalizeExtension(x) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// isAsset tells whether the file name is served as an asset.
+func (a *Asteroid) isAsset(name string) bool {
+	exts := a.AssetExtensions
+	if exts == nil {
+		exts = DefaultAssetExtensions
+	}
+	return hasExtension(name, exts)
+}
+
+// isHidden tells whether p (e.g. "sub/.git/config") is, or is in, a dotfile.
+func isHidden(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if strings.HasPrefix(elem, ".") && elem != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// svgPolicy is the Content-Security-Policy of SVG images: opened by themselves,
+// they are documents, whose scripts would run on the origin of the asteroid.
+const svgPolicy = "default-src 'none'; style-src 'unsafe-inline'"
+
+// serveAsset serves file, named name in the asteroid, with its MIME type
+// (or a sniffed one), range requests, and If-Modified-Since.
+// It is sent as an attachment if its extension is one of
+// Asteroid.DownloadExtensions, or if requested with ?download.
+func (a *Asteroid) serveAsset(w http.ResponseWriter, r *http.Request, app gotuna.App, cfg *Config, file fs.File, name string) {
+	stat, e := file.Stat()
+	if errors.Is(e, fs.ErrNotExist) {
+		a.notFound(w, r, app, cfg)
+		return
+	} else if e != nil {
+		renderError(a.logger(), app, cfg, w, r, http.StatusInternalServerError, fmt.Errorf("stat %s: %w", name, e))
+		return
+	}
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		b, e := io.ReadAll(file)
+		if errors.Is(e, fs.ErrNotExist) {
+			a.notFound(w, r, app, cfg)
+			return
+		} else if e != nil {
+			renderError(a.logger(), app, cfg, w, r, http.StatusInternalServerError, fmt.Errorf("reading %s: %w", name, e))
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+	if t := assetType(name); t != "" {
+		w.Header().Set("Content-Type", t)
+		if t == "image/svg+xml" {
+			w.Header().Set("Content-Security-Policy", svgPolicy)
+		}
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, download := r.URL.Query()["download"]; download || hasExtension(name, a.DownloadExtensions) {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(name)}))
+	}
+	http.ServeContent(w, r, path.Base(name), stat.ModTime(), content)
+}
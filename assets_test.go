@@ -0,0 +1,105 @@
+package gnAsteroid
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var assetsFS = fstest.MapFS{
+	"index.md":         {Data: []byte("# Home")},
+	"doc/manual.PDF":   {Data: []byte("%PDF-1.4 manual")},
+	"doc/data.csv":     {Data: []byte("a,b\n1,2\n")},
+	"doc/archive.zip":  {Data: []byte("PK zip")},
+	"media/song.mp3":   {Data: []byte("0123456789"), ModTime: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	"media/noext":      {Data: []byte("no extension")},
+	"deploy.sh":        {Data: []byte("#!/bin/sh")},
+	"id_rsa.pem":       {Data: []byte("secret")},
+	".env":             {Data: []byte("SECRET=1")},
+	".git/config":      {Data: []byte("[core]")},
+	"sub/.secret.json": {Data: []byte("{}")},
+	"img/logo.svg":     {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)},
+}
+
+func TestAssets(t *testing.T) {
+	asteroid := NewAsteroid(assetsFS, "Assets")
+	for _, tc := range []struct {
+		route, contentType string
+	}{
+		{"/doc/manual.PDF", "application/pdf"},
+		{"/doc/data.csv", "text/csv; charset=utf-8"},
+		{"/doc/archive.zip", "application/zip"},
+		{"/media/song.mp3", "audio/mpeg"},
+	} {
+		response := serveRoute(t, asteroid.Handler(), tc.route, nil)
+		require.Equal(t, http.StatusOK, response.Code, tc.route)
+		assert.Equal(t, tc.contentType, response.Header().Get("Content-Type"), tc.route)
+		assert.Equal(t, "nosniff", response.Header().Get("X-Content-Type-Options"), tc.route)
+		assert.Empty(t, response.Header().Get("Content-Disposition"), tc.route)
+		assert.Empty(t, response.Header().Get("Content-Security-Policy"), tc.route)
+	}
+	response := serveRoute(t, asteroid.Handler(), "/img/logo.svg", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "image/svg+xml", response.Header().Get("Content-Type"))
+	assert.Equal(t, "default-src 'none'; style-src 'unsafe-inline'", response.Header().Get("Content-Security-Policy"), "no scripts")
+	for _, route := range []string{"/media/noext", "/deploy.sh", "/id_rsa.pem", "/.env", "/.git/config", "/sub/.secret.json", "/nothing.pdf"} {
+		assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), route, nil).Code, route)
+	}
+}
+
+func TestAssetsRange(t *testing.T) {
+	asteroid := NewAsteroid(assetsFS, "Assets")
+	response := serveRoute(t, asteroid.Handler(), "/media/song.mp3", http.Header{"Range": {"bytes=2-5"}})
+	require.Equal(t, http.StatusPartialContent, response.Code)
+	assert.Equal(t, "2345", response.Body.String())
+	assert.Equal(t, "bytes 2-5/10", response.Header().Get("Content-Range"))
+	assert.Equal(t, "bytes", serveRoute(t, asteroid.Handler(), "/media/song.mp3", nil).Header().Get("Accept-Ranges"))
+
+	response = serveRoute(t, asteroid.Handler(), "/media/song.mp3", http.Header{"If-Modified-Since": {"Wed, 03 Jan 2024 00:00:00 GMT"}})
+	assert.Equal(t, http.StatusNotModified, response.Code)
+}
+
+// failingFS fails to read its file "doc/manual.PDF".
+type failingFS struct{ fstest.MapFS }
+
+type failingFile struct{ fs.File }
+
+func (f failingFile) Read([]byte) (int, error) {
+	return 0, errors.New("read /home/bob/asteroid/doc/manual.PDF: input/output error")
+}
+
+func (f failingFS) Open(name string) (fs.File, error) {
+	file, e := f.MapFS.Open(name)
+	if e == nil && name == "doc/manual.PDF" {
+		return failingFile{file}, nil
+	}
+	return file, e
+}
+
+func TestAssetsError(t *testing.T) {
+	response := serveRoute(t, NewAsteroid(failingFS{assetsFS}, "Assets").Handler(), "/doc/manual.PDF", nil)
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Contains(t, response.Body.String(), "500 - Internal Server Error", "themed")
+	assert.NotContains(t, response.Body.String(), "/home/bob", "details are logged, not shown")
+}
+
+func TestAssetsOptions(t *testing.T) {
+	asteroid := NewAsteroid(assetsFS, "Assets")
+	asteroid.AssetExtensions = []string{"sh", ".PDF"}
+	asteroid.DownloadExtensions = []string{".sh"}
+	response := serveRoute(t, asteroid.Handler(), "/deploy.sh", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `attachment; filename=deploy.sh`, response.Header().Get("Content-Disposition"))
+	response = serveRoute(t, asteroid.Handler(), "/doc/manual.PDF?download", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `attachment; filename=manual.PDF`, response.Header().Get("Content-Disposition"))
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), "/media/song.mp3", nil).Code)
+	// markdown is always served
+	assert.Equal(t, http.StatusOK, serveRoute(t, asteroid.Handler(), "/index.md", nil).Code)
+}
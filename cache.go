@@ -0,0 +1,157 @@
+package gnAsteroid
+
+import (
+	"container/list"
+	"log/slog"
+	"sync"
+	"time"
+
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+)
+
+// CacheConfig configures the in-process cache of realm renders (vm/qrender),
+// package files (vm/qfile) and function signatures (vm/qfuncs),
+// so that popular pages don't hammer the gnoland node.
+type CacheConfig struct {
+	MaxBytes  int           // size bound of cached results. 0 disables the cache
+	RenderTTL time.Duration // how long a vm/qrender result is fresh
+	FileTTL   time.Duration // how long vm/qfile and vm/qfuncs results are fresh (packages are immutable, it can be long)
+	Stale     time.Duration // how long after its TTL a result is still served, while being refreshed in the background
+}
+
+func NewDefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		MaxBytes:  32 << 20,
+		RenderTTL: 10 * time.Second,
+		FileTTL:   10 * time.Minute,
+		Stale:     time.Minute,
+	}
+}
+
+// queryCache is a size-bounded, least-recently-used cache of query results, expiring after a TTL.
+type queryCache struct {
+	cfg    CacheConfig
+	logger *slog.Logger
+	now    func() time.Time // time.Now, but for tests
+
+	mu         sync.Mutex
+	size       int                        // bytes, see cacheEntry.size()
+	entries    map[cacheKey]*list.Element // values are *cacheEntry
+	lru        *list.List                 // most recently used first
+	refreshing map[cacheKey]bool
+}
+
+// cacheKey identifies the result of a query at a block height. Queries are made at
+// the latest height, so results are looked up at the latest height seen by the client
+// (see ChainClient.height): a new block makes them miss, even if they are still fresh.
+type cacheKey struct {
+	qpath  string
+	data   string
+	height int64
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	res       *abci.ResponseQuery
+	fetchedAt time.Time
+}
+
+func (e *cacheEntry) size() int {
+	return len(e.key.qpath) + len(e.key.data) + len(e.res.Data) + len(e.res.Value) + len(e.res.Key)
+}
+
+// newQueryCache returns nil if cfg disables caching.
+func newQueryCache(logger *slog.Logger, cfg CacheConfig) *queryCache {
+	if cfg.MaxBytes <= 0 {
+		return nil
+	}
+	return &queryCache{
+		cfg:        cfg,
+		logger:     logger,
+		now:        time.Now,
+		entries:    map[cacheKey]*list.Element{},
+		lru:        list.New(),
+		refreshing: map[cacheKey]bool{},
+	}
+}
+
+// ttl returns how long results of qpath are fresh, 0 if they are not cached.
+func (c *queryCache) ttl(qpath string) time.Duration {
+	switch qpath {
+	case "vm/qrender":
+		return c.cfg.RenderTTL
+	case qFileStr, "vm/qfuncs":
+		return c.cfg.FileTTL
+	}
+	return 0
+}
+
+// get returns a cached result, and whether it must be refreshed.
+// refresh is true at most once per key at a time, until put or abandon are called.
+func (c *queryCache) get(key cacheKey) (res *abci.ResponseQuery, refresh bool) {
+	ttl := c.ttl(key.qpath)
+	if ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.logger.Debug("cache miss", "path", key.qpath, "data", key.data)
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	age := c.now().Sub(entry.fetchedAt)
+	switch {
+	case age <= ttl:
+		c.logger.Debug("cache hit", "path", key.qpath, "data", key.data, "age", age)
+		c.lru.MoveToFront(elem)
+		return entry.res, false
+	case age <= ttl+c.cfg.Stale:
+		c.logger.Debug("cache hit (stale)", "path", key.qpath, "data", key.data, "age", age)
+		c.lru.MoveToFront(elem)
+		refresh = !c.refreshing[key]
+		c.refreshing[key] = true
+		return entry.res, refresh
+	default:
+		c.logger.Debug("cache miss (expired)", "path", key.qpath, "data", key.data, "age", age)
+		c.remove(elem)
+		return nil, false
+	}
+}
+
+// put stores a result, evicting the least recently used ones if needed.
+func (c *queryCache) put(key cacheKey, res *abci.ResponseQuery) {
+	if c.ttl(key.qpath) <= 0 {
+		return
+	}
+	entry := &cacheEntry{key: key, res: res, fetchedAt: c.now()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	if entry.size() > c.cfg.MaxBytes {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += entry.size()
+	for c.size > c.cfg.MaxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// abandon is called when a refresh failed, so a later get can try again.
+func (c *queryCache) abandon(key cacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+}
+
+func (c *queryCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size()
+}
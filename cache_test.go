@@ -0,0 +1,88 @@
+package gnAsteroid
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCache(t *testing.T) {
+	node := echoNode(t, 0)
+	cfg := configWith(node.URL)
+	cfg.Cache = CacheConfig{MaxBytes: 1 << 10, RenderTTL: time.Second, FileTTL: time.Hour, Stale: time.Second}
+	chain := NewChainClient(slog.Default(), &cfg)
+	now := time.Now()
+	chain.cache.now = func() time.Time { return now }
+	query := func(qpath, data string) string {
+		t.Helper()
+		res, e := chain.makeRequest(context.Background(), qpath, []byte(data))
+		require.NoError(t, e)
+		return string(res.Data)
+	}
+
+	// miss, then hit
+	assert.Equal(t, "vm/qrender:gno.land/r/demo/users:", query("vm/qrender", "gno.land/r/demo/users:"))
+	assert.Equal(t, "vm/qrender:gno.land/r/demo/users:", query("vm/qrender", "gno.land/r/demo/users:"))
+	assert.Equal(t, int32(1), node.queries.Load())
+	query("vm/qrender", "gno.land/r/demo/users:moul")
+	assert.Equal(t, int32(2), node.queries.Load(), "data is part of the key")
+
+	// not cached
+	query(".app/version", "")
+	query(".app/version", "")
+	assert.Equal(t, int32(4), node.queries.Load())
+
+	// stale: served from cache, refreshed in the background once
+	now = now.Add(1500 * time.Millisecond)
+	query("vm/qrender", "gno.land/r/demo/users:")
+	query("vm/qrender", "gno.land/r/demo/users:")
+	assert.Eventually(t, func() bool { return node.queries.Load() == 5 }, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, refresh := chain.cache.get(cacheKey{qpath: "vm/qrender", data: "gno.land/r/demo/users:", height: 42})
+		return !refresh
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(5), node.queries.Load())
+
+	// expired beyond stale: miss
+	now = now.Add(3 * time.Second)
+	query("vm/qrender", "gno.land/r/demo/users:moul")
+	assert.Equal(t, int32(6), node.queries.Load())
+
+	// package files live longer
+	query(qFileStr, "gno.land/r/demo/users")
+	now = now.Add(time.Minute)
+	query(qFileStr, "gno.land/r/demo/users")
+	assert.Equal(t, int32(7), node.queries.Load())
+
+	// a new block: results of the previous one miss
+	query(qFileStr, "gno.land/r/demo/boards")
+	assert.Equal(t, int32(8), node.queries.Load())
+	node.height.Store(43)
+	query("vm/qrender", "gno.land/r/demo/users:moul") // fresh, but a new height is seen
+	query(qFileStr, "gno.land/r/demo/boards")
+	assert.Equal(t, int32(10), node.queries.Load())
+	assert.Equal(t, int64(43), chain.height.Load())
+	query(qFileStr, "gno.land/r/demo/boards")
+	assert.Equal(t, int32(10), node.queries.Load())
+
+	// size bound: least recently used results are evicted
+	cfg.Cache.MaxBytes = 700 // each result below is 319 bytes
+	chain = NewChainClient(slog.Default(), &cfg)
+	node.queries.Store(0)
+	big := strings.Repeat("x", 150)
+	query(qFileStr, big+"1")
+	query(qFileStr, big+"2")
+	query(qFileStr, big+"1") // hit, "1" is now more recent than "2"
+	query(qFileStr, big+"3") // evicts "2"
+	assert.Equal(t, int32(3), node.queries.Load())
+	assert.LessOrEqual(t, chain.cache.size, cfg.Cache.MaxBytes)
+	query(qFileStr, big+"1")
+	assert.Equal(t, int32(3), node.queries.Load())
+	query(qFileStr, big+"2")
+	assert.Equal(t, int32(4), node.queries.Load())
+}
@@ -0,0 +1,231 @@
+package gnAsteroid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+	"github.com/gnolang/gno/tm2/pkg/bft/rpc/client"
+	rpctypes "github.com/gnolang/gno/tm2/pkg/bft/rpc/lib/types"
+)
+
+const defaultQueryTimeout = 10 * time.Second
+
+// errChainUnreachable wraps errors of queries which didn't get an answer from the node,
+// unlike errors returned by the node (e.g. a realm without Render()).
+var errChainUnreachable = errors.New("chain unreachable")
+
+// ChainClient queries the gnoland node at Config.RemoteAddr, with the query timeout,
+// cache and snapshots of the Config it is made from (see NewChainClient): it doesn't
+// see later changes of the Config, a new one must be made then.
+// It is meant to be shared, e.g. by the routers of each theme of an asteroid and their
+// reloads (see Options.Chain and Asteroid.Chain), so that connections to the node
+// and cached results are reused between page views.
+type ChainClient struct {
+	logger    *slog.Logger
+	timeout   time.Duration  // per query, see Config.QueryTimeout
+	caller    rpcCaller      // of tm2's RPC client
+	cache     *queryCache    // nil if disabled, see Config.Cache
+	height    atomic.Int64   // latest block height seen in query results, see cacheKey
+	snapshots *snapshotStore // nil if disabled, see Config.SnapshotDir
+}
+
+// queryResult is the result of an ABCI query.
+type queryResult struct {
+	*abci.ResponseQuery
+	Snapshot *snapshot // not nil if the chain is unreachable and the result is a snapshot
+}
+
+func NewChainClient(logger *slog.Logger, cfg *Config) *ChainClient {
+	timeout := cfg.QueryTimeout
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+	return &ChainClient{
+		logger:  logger,
+		timeout: timeout,
+		caller: rpcCaller{
+			url: rpcURL(cfg.RemoteAddr),
+			client: &http.Client{
+				Transport: &http.Transport{
+					Proxy:               http.ProxyFromEnvironment,
+					DialContext:         (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+					DisableCompression:  true, // like tm2's client, prevents GZIP-bomb DoS attacks
+					MaxIdleConnsPerHost: 16,
+					MaxConnsPerHost:     64, // a slow node makes requests wait (up to timeout), not pile up
+					IdleConnTimeout:     90 * time.Second,
+					TLSHandshakeTimeout: timeout,
+					ForceAttemptHTTP2:   true,
+				},
+			},
+		},
+		cache:     newQueryCache(logger, cfg.Cache),
+		snapshots: newSnapshotStore(logger, cfg.SnapshotDir),
+	}
+}
+
+// rpcCaller sends the JSON-RPC requests of tm2's RPC client (see rpcclient.Client)
+// with a configured http.Client, which tm2's HTTP caller doesn't allow.
+type rpcCaller struct {
+	url    string // e.g. "http://127.0.0.1:26657"
+	client *http.Client
+	ctx    context.Context // if not nil, requests are sent within it, see within
+}
+
+// within returns a copy of c sending requests within ctx, e.g. canceled when the visitor
+// goes away: the methods of tm2's RPC client only give a context having their timeout.
+func (c rpcCaller) within(ctx context.Context) rpcCaller {
+	c.ctx = ctx
+	return c
+}
+
+func (c rpcCaller) SendRequest(ctx context.Context, request rpctypes.RPCRequest) (*rpctypes.RPCResponse, error) {
+	var response rpctypes.RPCResponse
+	if err := c.post(ctx, request, &response); err != nil {
+		return nil, err
+	} else if response.ID != request.ID {
+		return nil, errors.New("request / response ID mismatch")
+	}
+	return &response, nil
+}
+
+func (c rpcCaller) SendBatch(ctx context.Context, requests rpctypes.RPCRequests) (rpctypes.RPCResponses, error) {
+	var responses rpctypes.RPCResponses
+	if err := c.post(ctx, requests, &responses); err != nil {
+		return nil, err
+	} else if len(responses) != len(requests) {
+		return nil, errors.New("invalid batch response size")
+	}
+	return responses, nil
+}
+
+func (c rpcCaller) Close() error { return nil }
+
+func (c rpcCaller) post(ctx context.Context, request, response any) error {
+	if c.ctx != nil {
+		ctx = c.ctx
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("invalid status code received, %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// rpcURL turns a remote address as accepted by tm2's client
+// (e.g. "127.0.0.1:26657", "tcp://...", "https://rpc.gno.land:443") into an http(s) URL.
+func rpcURL(remoteAddr string) string {
+	protocol, address, found := strings.Cut(remoteAddr, "://")
+	if !found {
+		protocol, address = "http", remoteAddr
+	} else if protocol != "https" {
+		protocol = "http"
+	}
+	return protocol + "://" + address
+}
+
+// makeRequest does an ABCI query (e.g. qpath "vm/qrender"), or returns its cached result.
+// It is canceled when ctx is (e.g. the visitor went away), or after the query timeout.
+// If the chain is unreachable, the last snapshot of the result is returned, if any.
+func (c *ChainClient) makeRequest(ctx context.Context, qpath string, data []byte) (*queryResult, error) {
+	key := cacheKey{qpath: qpath, data: string(data), height: c.height.Load()}
+	if c.cache != nil {
+		if res, refresh := c.cache.get(key); res != nil {
+			if refresh {
+				go c.refresh(key)
+			}
+			return &queryResult{ResponseQuery: res}, nil
+		}
+	}
+	res, err := c.query(ctx, qpath, data)
+	if err != nil {
+		if c.snapshots != nil && errors.Is(err, errChainUnreachable) {
+			if res, snapshot := c.snapshots.load(qpath, data); res != nil {
+				c.logger.Warn("serving snapshot", "path", qpath, "data", string(data), "height", snapshot.Height, "fetched_at", snapshot.FetchedAt)
+				return &queryResult{ResponseQuery: res, Snapshot: snapshot}, nil
+			}
+		}
+		return nil, err
+	}
+	if c.cache != nil {
+		c.cache.put(c.seen(key, res), res)
+	}
+	if c.snapshots != nil {
+		c.snapshots.save(qpath, data, res)
+	}
+	return &queryResult{ResponseQuery: res}, nil
+}
+
+// refresh updates a stale cached result, regardless of the visitor who triggered it.
+func (c *ChainClient) refresh(key cacheKey) {
+	data := []byte(key.data)
+	res, err := c.query(context.Background(), key.qpath, data)
+	if err != nil {
+		c.cache.abandon(key)
+		return
+	}
+	if fresh := c.seen(key, res); fresh != key {
+		c.cache.put(fresh, res)
+		c.cache.abandon(key)
+	} else {
+		c.cache.put(key, res)
+	}
+	if c.snapshots != nil {
+		c.snapshots.save(key.qpath, data, res)
+	}
+}
+
+// seen records the height of res, the result of the query of key,
+// and returns its key at this height.
+func (c *ChainClient) seen(key cacheKey, res *abci.ResponseQuery) cacheKey {
+	for latest := c.height.Load(); res.Height > latest; latest = c.height.Load() {
+		if c.height.CompareAndSwap(latest, res.Height) {
+			break
+		}
+	}
+	key.height = res.Height
+	return key
+}
+
+func (c *ChainClient) query(ctx context.Context, qpath string, data []byte) (*abci.ResponseQuery, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	rpc := client.NewRPCClient(c.caller.within(queryCtx), client.WithRequestTimeout(c.timeout))
+	result, err := rpc.ABCIQueryWithOptions(qpath, data, client.ABCIQueryOptions{})
+	if err != nil && ctx.Err() != nil {
+		// the visitor went away, the node isn't to blame
+		c.logger.Debug("request canceled", "path", qpath, "error", ctx.Err())
+		return nil, fmt.Errorf("unable to query path %q: %w", qpath, ctx.Err())
+	} else if err != nil {
+		c.logger.Error("request error", "path", qpath, "error", err)
+		return nil, fmt.Errorf("unable to query path %q: %w: %w", qpath, errChainUnreachable, err)
+	}
+	if result.Response.Error != nil {
+		c.logger.Error("response error", "path", qpath, "log", result.Response.Log)
+		return nil, result.Response.Error
+	}
+	return &result.Response, nil
+}
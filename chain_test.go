@@ -0,0 +1,141 @@
+package gnAsteroid
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+	ctypes "github.com/gnolang/gno/tm2/pkg/bft/rpc/core/types"
+	rpctypes "github.com/gnolang/gno/tm2/pkg/bft/rpc/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNode struct {
+	*httptest.Server
+	connections atomic.Int32
+	queries     atomic.Int32
+	height      atomic.Int64 // of the results, 42 unless changed
+}
+
+// echoNode is a fake gnoland node answering abci_query with "<path>:<data>",
+// after waiting delay.
+func echoNode(t *testing.T, delay time.Duration) *fakeNode {
+	node := &fakeNode{}
+	node.height.Store(42)
+	node.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		node.queries.Add(1)
+		var request rpctypes.RPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		var params struct {
+			Path string `json:"path"`
+			Data []byte `json:"data"`
+		}
+		require.NoError(t, amino.UnmarshalJSON(request.Params, &params))
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(request.ID, ctypes.ResultABCIQuery{
+			Response: abci.ResponseQuery{
+				ResponseBase: abci.ResponseBase{Data: []byte(params.Path + ":" + string(params.Data))},
+				Height:       node.height.Load(),
+			},
+		}))
+	}))
+	node.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			node.connections.Add(1)
+		}
+	}
+	node.Start()
+	t.Cleanup(node.Close)
+	return node
+}
+
+func TestChainClient(t *testing.T) {
+	node := echoNode(t, 0)
+	cfg := configWith(node.URL)
+	chain := NewChainClient(slog.Default(), &cfg)
+	for i := 0; i < 5; i++ {
+		res, e := chain.makeRequest(context.Background(), "vm/qrender", []byte("gno.land/r/demo/users:"))
+		require.NoError(t, e)
+		assert.Equal(t, "vm/qrender:gno.land/r/demo/users:", string(res.Data))
+		assert.Equal(t, int64(42), res.Height)
+	}
+	assert.Equal(t, int32(5), node.queries.Load())
+	assert.Equal(t, int32(1), node.connections.Load(), "connections should be reused")
+}
+
+func TestChainClientTimeout(t *testing.T) {
+	node := echoNode(t, time.Minute)
+	cfg := configWith(node.URL)
+	cfg.QueryTimeout = 50 * time.Millisecond
+	chain := NewChainClient(slog.Default(), &cfg)
+
+	start := time.Now()
+	_, e := chain.makeRequest(context.Background(), "vm/qrender", nil)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// canceled by the incoming request
+	cfg.QueryTimeout = time.Minute
+	chain = NewChainClient(slog.Default(), &cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	_, e = chain.makeRequest(ctx, "vm/qrender", nil)
+	assert.ErrorIs(t, e, context.Canceled)
+	assert.NotErrorIs(t, e, errChainUnreachable, "no snapshot fallback for visitors gone away")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestChainClientShared(t *testing.T) {
+	node := echoNode(t, 0)
+	cfg := configWith(node.URL)
+	cfg.Cache = NewDefaultCacheConfig()
+	asteroid := NewAsteroid(fstest.MapFS{"index.md": {Data: []byte("# Home")}}, "Shared")
+	asteroid.Config = &cfg
+	asteroid.Chain = NewChainClient(slog.Default(), &cfg)
+	get := func() {
+		require.Equal(t, http.StatusOK, serveRoute(t, asteroid.Handler(), "/r/demo/users", nil).Code)
+	}
+	var queries int32
+	for i := 0; i < 3; i++ { // reloads
+		get()
+		if i == 0 {
+			queries = node.queries.Load()
+		}
+	}
+	assert.Equal(t, int32(1), node.connections.Load(), "connections should be reused")
+	assert.Equal(t, queries, node.queries.Load(), "results should be cached")
+
+	// without a ChainClient, each handler makes its own, from the Config of the moment
+	asteroid.Chain = nil
+	other := echoNode(t, 0)
+	cfg.RemoteAddr = other.URL
+	get()
+	assert.Equal(t, queries, node.queries.Load())
+	assert.Equal(t, queries, other.queries.Load())
+}
+
+func TestRPCURL(t *testing.T) {
+	for remote, url := range map[string]string{
+		"127.0.0.1:26657":          "http://127.0.0.1:26657",
+		"tcp://127.0.0.1:26657":    "http://127.0.0.1:26657",
+		"http://127.0.0.1:26657":   "http://127.0.0.1:26657",
+		"https://rpc.gno.land:443": "https://rpc.gno.land:443",
+	} {
+		assert.Equal(t, url, rpcURL(remote))
+	}
+}
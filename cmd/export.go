@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gnAsteroid/gnAsteroid"
+	osm "github.com/gnolang/gno/tm2/pkg/os"
+)
+
+// gnAsteroid export -asteroid-dir <dir> -out <dir>
+//
+// Writes the asteroid as a static website, exits with 1 on broken internal links.
+func export(args []string, logger *slog.Logger) error {
+	flag := flag.NewFlagSet("export", flag.ContinueOnError)
+	var asteroidDir, asteroidName, themeDir, outDir, chainURL, siteURL string
+	var feedDirs, taxonomies, assetExts []string
+	var dirIndex, containSymlinks bool
+	flag.StringVar(&asteroidDir, "asteroid-dir", "", "wiki directory location. [Mandatory!]")
+	flag.StringVar(&asteroidName, "asteroid-name", "CHANGEME", "the asteroid name (website title). read from .TITLE, or CHANGEME")
+	flag.StringVar(&themeDir, "theme-dir", "", "theme directory (css, js, img). Default is 'themes/default.theme/'")
+	flag.StringVar(&outDir, "out", "", "output directory, e.g. public/ [Mandatory!]")
+	flag.StringVar(&chainURL, "chain-url", "https://gno.land", "links to realms (/r/...) and packages (/p/...) point there. if empty, they are kept as is")
+	flag.StringVar(&siteURL, "site-url", "", "where the website will be published, e.g. https://example.com or https://example.com/wiki. feeds are exported only if given")
+	flag.Func("feed-dir", "only pages under this directory (e.g. blog) are in the feeds, can be repeated", func(s string) error {
+		feedDirs = append(feedDirs, s)
+		return nil
+	})
+	flag.BoolVar(&dirIndex, "dir-index", false, "export a listing of the pages of directories having neither index.md nor README.md")
+	flag.BoolVar(&containSymlinks, "contain-symlinks", false, "refuse to export the files of symlinks pointing outside of the asteroid directory")
+	flag.Func("taxonomy", "front matter list whose terms are listed at /<taxonomy>/, e.g. series, can be repeated. default is tags", func(s string) error {
+		taxonomies = append(taxonomies, s)
+		return nil
+	})
+	flag.Func("asset-ext", "extension of the files exported as is (e.g. .pdf), can be repeated. default is "+strings.Join(gnAsteroid.DefaultAssetExtensions, " "), func(s string) error {
+		assetExts = append(assetExts, s)
+		return nil
+	})
+	if e := flag.Parse(args); e != nil {
+		return e
+	}
+	if asteroidDir == "" {
+		return errors.New("-asteroid-dir is mandatory")
+	} else if outDir == "" {
+		return errors.New("-out is mandatory")
+	} else if !osm.DirExists(asteroidDir) {
+		return errors.New(asteroidDir + " is not a directory")
+	} else if themeDir != "" && !osm.DirExists(themeDir) {
+		return errors.New(themeDir + " is not a directory. -theme-dir must exist, if supplied.")
+	}
+	theme, e := ThemeFsFrom(themeDir)
+	if e != nil {
+		return e
+	}
+	asteroid := &gnAsteroid.Asteroid{
+		FS:      AsteroidFsFrom(asteroidDir, containSymlinks),
+		Name:    asteroidNameFrom(asteroidDir, asteroidName, logger),
+		ThemeFS: theme,
+		Logger:  logger,
+
+		FeedDirs:   feedDirs,
+		Taxonomies: taxonomies,
+		DirIndex:   dirIndex,
+
+		AssetExtensions: assetExts,
+	}
+	broken, e := asteroid.Export(outDir, chainURL, siteURL)
+	if e != nil {
+		return e
+	}
+	for _, b := range broken {
+		fmt.Fprintln(os.Stderr, "broken link:", b)
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("%d broken link(s)", len(broken))
+	}
+	logger.Info(fmt.Sprintf("Exported %s to %s", asteroidDir, outDir))
+	return nil
+}
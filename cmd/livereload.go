@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// liveReloadPath is the Server-Sent Events stream of -livereload, under the prefix of each vhost.
+// Dotfiles are never served by asteroids, so it can't hide a page.
+const liveReloadPath = "/.livereload"
+
+// liveReloadKeepAlive is the interval of comments sent to keep streams open through proxies.
+const liveReloadKeepAlive = 30 * time.Second
+
+// liveReload streams "reload" and "css" events to the open pages (see static/js/livereload.js)
+// of each vhost.
+type liveReload struct {
+	vhosts  vhostRouter
+	mu      sync.Mutex
+	clients map[chan string]*vhost // -> the vhost of the page
+	done    chan struct{}          // closed when shutting down
+	once    sync.Once
+}
+
+func newLiveReload(vhosts []*vhost) *liveReload {
+	return &liveReload{vhosts: vhosts, clients: map[chan string]*vhost{}, done: make(chan struct{})}
+}
+
+// close ends the event streams, e.g. for the server to shut down.
+func (lr *liveReload) close() {
+	lr.once.Do(func() { close(lr.done) })
+}
+
+// notify sends event ("reload" or "css") to the open pages of v.
+func (lr *liveReload) notify(v *vhost, event string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client, page := range lr.clients {
+		if page != v {
+			continue
+		}
+		select {
+		case client <- event:
+		default: // an event is already pending for this client
+		}
+	}
+}
+
+// stream returns the vhost whose event stream is requested by r, nil if r isn't for a stream.
+func (lr *liveReload) stream(r *http.Request) *vhost {
+	for _, v := range lr.vhosts {
+		if v.matches(r) {
+			if r.URL.Path == v.prefix+liveReloadPath {
+				return v
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// serve streams the events of v.
+func (lr *liveReload) serve(w http.ResponseWriter, r *http.Request, v *vhost) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	client := make(chan string, 1)
+	lr.mu.Lock()
+	lr.clients[client] = v
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, client)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+	keepAlive := time.NewTicker(liveReloadKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event := <-client:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		case <-lr.done:
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handler serves the event streams at liveReloadPath under the prefix of each vhost, and next otherwise.
+func (lr *liveReload) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := lr.stream(r); v != nil {
+			lr.serve(w, r, v)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
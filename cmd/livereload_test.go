@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnAsteroid/gnAsteroid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveReloadStream(t *testing.T) {
+	wiki, root := &vhost{prefix: "/wiki"}, &vhost{}
+	lr := newLiveReload([]*vhost{wiki, root})
+	server := httptest.NewServer(lr.handler(http.NotFoundHandler()))
+	defer server.Close()
+
+	response, e := http.Get(server.URL + "/wiki" + liveReloadPath)
+	require.NoError(t, e)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	lines := bufio.NewReader(response.Body)
+	line, e := lines.ReadString('\n')
+	require.NoError(t, e)
+	assert.Equal(t, "retry: 1000\n", line)
+
+	lr.notify(root, "reload") // not for the pages of wiki
+	lr.notify(wiki, "css")
+	var event []string
+	for len(event) < 3 {
+		line, e := lines.ReadString('\n')
+		require.NoError(t, e)
+		if line = strings.TrimSpace(line); line != "" || len(event) > 0 {
+			event = append(event, line)
+		}
+	}
+	assert.Equal(t, []string{"event: css", "data: css", ""}, event)
+
+	for _, route := range []string{"/other", "/other" + liveReloadPath, "/wiki/other" + liveReloadPath} {
+		response, e = http.Get(server.URL + route)
+		require.NoError(t, e)
+		response.Body.Close()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode, route)
+	}
+}
+
+func TestLiveReloadEvents(t *testing.T) {
+	v := testVhost(t)
+	rl := newReloader(slog.Default(), gnAsteroid.NewDefaultConfig(), 10*time.Millisecond, time.Second)
+	events := make(chan bool, 10)
+	rl.reloaded = func(_ *vhost, cssOnly bool, _ error) { events <- cssOnly }
+	stop := make(chan struct{})
+	defer close(stop)
+	go rl.run(stop)
+
+	css := filepath.Join(v.themeDir, "css", "common.css")
+	for _, tc := range []struct {
+		changed []string
+		cssOnly bool
+	}{
+		{[]string{css}, true},
+		{[]string{css, css}, true},
+		{[]string{css, filepath.Join(v.asteroidDir, "index.md")}, false},
+		{[]string{filepath.Join(v.themeDir, "img", "logo.png")}, false},
+		{[]string{""}, false}, // SIGUSR1
+	} {
+		for _, name := range tc.changed {
+			rl.request(v, name)
+		}
+		select {
+		case cssOnly := <-events:
+			assert.Equal(t, tc.cssOnly, cssOnly, tc.changed)
+		case <-time.After(5 * time.Second):
+			t.Fatal("not reloaded")
+		}
+	}
+}
+
+func TestLiveReloadScript(t *testing.T) {
+	v := testVhost(t)
+	assert.NotContains(t, getHome(v), "livereload.js")
+	cfg, e := parseArgs([]string{"-asteroid-dir", "../example", "-livereload"}, slog.Default())
+	require.NoError(t, e)
+	require.Equal(t, liveReloadPath, cfg.LiveReload)
+	v.asteroid = nil
+	require.NoError(t, v.reload(slog.Default(), cfg))
+	assert.Contains(t, getHome(v), `<script type="text/javascript" src="/static/js/livereload.js" data-url="/.livereload"></script>`)
+}
@@ -1,19 +1,22 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/dietsche/rfsnotify"
 	"github.com/gnAsteroid/gnAsteroid"
 	"github.com/gnolang/gno/gno.land/pkg/log"
 	osm "github.com/gnolang/gno/tm2/pkg/os"
@@ -22,108 +25,344 @@ import (
 
 var bindAddr string
 var themeDir string
-var asteroidDir string // asteroidDir will be read and become asteroidFs
+var themesDir string
+var asteroidDir string  // asteroidDir will be read and become asteroidFs
+var vhosts []*vhost     // -vhost flags, then -asteroid-dir (if any) as the default vhost
+var mockChainDir string // fixtures of a fake gnoland node, see gnAsteroid.MockChain
+var reloadDebounce time.Duration
+var reloadMaxWait time.Duration
+var drainTimeout time.Duration // graceful shutdown
+var tlsCert, tlsKey string     // if set, serve https
+var httpRedirect string        // if set, redirect http requests on this address to https
 
-// Launch a gnAsteroid server (using gnoweb) on bindAddr
-// Watch asteroid, theme dirs, SIGUSR1 for reload.
+// Launch a gnAsteroid server (using gnoweb) on bindAddr, in https with -tls-cert
+// Watch asteroid, theme dirs, SIGUSR1 (or SIGHUP) for reload.
+// Shut down gracefully on SIGINT or SIGTERM.
+// Or, with `gnAsteroid export`, write the asteroid as a static website,
+// and with `gnAsteroid theme check`, validate themes.
 func main() {
 	zapLogger := log.NewZapConsoleLogger(os.Stdout, zapcore.InfoLevel)
 	defer zapLogger.Sync()
 	logger := log.ZapLoggerToSlog(zapLogger)
 
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if e := export(os.Args[2:], logger); e != nil {
+			fmt.Fprintf(os.Stderr, "%+v\n", e)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "theme" {
+		if e := theme(os.Args[2:], logger); e != nil {
+			fmt.Fprintf(os.Stderr, "%+v\n", e)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, e := parseArgs(os.Args[1:], logger)
 	if e != nil {
 		fmt.Fprintf(os.Stderr, "%+v\n", e)
 		os.Exit(1)
 	}
-	logger.Info(fmt.Sprintf("Serving %s on http://%s", asteroidDir, bindAddr))
-	themeFs := ThemeFsFrom(themeDir)
+	if mockChainDir != "" {
+		if cfg.RemoteAddr, e = serveMockChain(mockChainDir, logger); e != nil {
+			fmt.Fprintf(os.Stderr, "%+v\n", e)
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("Serving mock chain %s on %s", mockChainDir, cfg.RemoteAddr))
+	}
+	var cert *certificate
+	scheme := "http"
+	if tlsCert != "" {
+		if cert, e = loadCertificate(tlsCert, tlsKey); e != nil {
+			fmt.Fprintf(os.Stderr, "%+v\n", e)
+			os.Exit(1)
+		}
+		scheme = "https"
+	}
+	chain := gnAsteroid.NewChainClient(logger, cfg) // once cfg.RemoteAddr is known
+	for _, v := range vhosts {
+		v.chain = chain
+		if e := v.reload(logger, cfg); e != nil {
+			// served as an error page, until fixed on disk
+			logger.Error("serving "+v.asteroidDir, "error", e)
+		}
+		logger.Info(fmt.Sprintf("Serving %s on %s://%s%s%s", v.asteroidDir, scheme, v.host, bindAddr, v.prefix))
+	}
+	var handler http.Handler = vhostRouter(vhosts)
+	reloader := newReloader(logger, cfg, reloadDebounce, reloadMaxWait)
 	server := &http.Server{
 		Addr:              bindAddr,
 		ReadHeaderTimeout: 60 * time.Second,
-		Handler:           gnAsteroid.MakeApp(logger, cfg, themeFs),
 	}
+	if cfg.LiveReload != "" {
+		lr := newLiveReload(vhosts)
+		handler = lr.handler(handler)
+		reloader.reloaded = func(v *vhost, cssOnly bool, e error) {
+			if e != nil {
+				return
+			} else if cssOnly {
+				lr.notify(v, "css")
+			} else {
+				lr.notify(v, "reload")
+			}
+		}
+		server.RegisterOnShutdown(lr.close) // event streams never end by themselves
+	}
+	server.Handler = handler
+	if cert != nil {
+		server.TLSConfig = cert.tlsConfig()
+	}
+	stopReloader := make(chan struct{})
+	go reloader.run(stopReloader)
 
-	// SIGUSR1 -> invalidate all templates
+	// SIGUSR1, or SIGHUP -> reload all vhosts
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGUSR1)
+	signal.Notify(sigChan, syscall.SIGUSR1, syscall.SIGHUP)
 	go func() {
-		for {
-			sig := <-sigChan
-			switch sig {
-			case syscall.SIGUSR1:
-				fmt.Println("received SIGUSR1.")
-				server.Handler = gnAsteroid.MakeApp(logger, cfg, themeFs)
+		for sig := range sigChan {
+			logger.Info(fmt.Sprintf("received %s, reloading", sig))
+			for _, v := range vhosts {
+				reloader.request(v, "")
 			}
 		}
 	}()
 
-	// Watch over asteroidDir for any change -> invalidate all templates
-	if watcher, err := rfsnotify.NewWatcher(); err == nil {
-		defer watcher.Close()
-		go func() {
-			for {
-				select {
-				case event, ok := <-watcher.Events:
-					if ok {
-						logger.Info("Reloading, modified: " + event.Name)
-						server.Handler = gnAsteroid.MakeApp(logger, cfg, themeFs)
-					}
+	// Watch over asteroid and theme dirs for any change -> reload their vhosts
+	watcher, err := newWatcher(logger)
+	if err == nil {
+		for _, v := range vhosts {
+			for _, dir := range []string{v.asteroidDir, v.themeDir, v.themesDir} {
+				if dir == "" {
+					continue
+				} else if e := watcher.addRecursive(dir); e != nil {
+					logger.Error("watching "+dir, "error", e)
 				}
 			}
-		}()
-		watcher.AddRecursive(asteroidDir)
-		if themeDir != "" {
-			watcher.AddRecursive(themeDir)
 		}
+		if cert != nil {
+			for _, dir := range cert.dirs() {
+				if e := watcher.add(dir); e != nil {
+					logger.Error("watching "+dir, "error", e)
+				}
+			}
+		}
+		go watcher.run(func(name string) {
+			if cert != nil && cert.watches(name) {
+				cert.reloadAfter(reloadDebounce, logger)
+			}
+			for _, v := range vhosts {
+				if v.watches(name) {
+					logger.Debug("modified: " + name)
+					reloader.request(v, name)
+				}
+			}
+		})
+	} else {
+		logger.Error("files are not watched", "error", err)
 	}
 
-	if err := server.ListenAndServe(); err != nil {
+	// SIGINT, SIGTERM -> graceful shutdown, a second one exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	redirected := make(chan error, 1)
+	if httpRedirect == "" {
+		redirected <- nil
+	} else if listener, e := net.Listen("tcp", httpRedirect); e != nil {
+		redirected <- e
+		cancel()
+	} else {
+		logger.Info(fmt.Sprintf("Redirecting http://%s to https", httpRedirect))
+		redirect := &http.Server{Handler: redirectToHTTPS(bindAddr), ReadHeaderTimeout: server.ReadHeaderTimeout}
+		go func() { redirected <- serve(ctx, logger, redirect, listener, drainTimeout) }()
+	}
+	listener, err := net.Listen("tcp", bindAddr)
+	if err == nil {
+		err = serve(ctx, logger, server, listener, drainTimeout)
+	}
+	cancel()
+	if e := <-redirected; err == nil {
+		err = e
+	}
+	signal.Stop(sigChan)
+	if watcher != nil {
+		watcher.Close()
+	}
+	close(stopReloader)
+	if err != nil {
 		logger.Error(fmt.Sprintf("HTTP server stopped with error: %+v\n", err))
+		zapLogger.Sync()
+		os.Exit(1)
 	}
+	logger.Info("Stopped")
 	zapLogger.Sync()
 }
 
 func parseArgs(args []string, logger *slog.Logger) (*gnAsteroid.Config, error) {
 	cfg := gnAsteroid.NewDefaultConfig()
+	cfg.Cache = gnAsteroid.NewDefaultCacheConfig() // opt-in for library users, on by default here
 	flag := flag.NewFlagSet("gnoweb", flag.ContinueOnError)
 	// gnAsteroid flags
 	var asteroidName string
-	flag.StringVar(&asteroidDir, "asteroid-dir", "", "wiki directory location. [Mandatory!]")
+	var feedDirs, taxonomies, assetExts, downloadExts []string
+	var dirIndex, drafts, containSymlinks bool
+	flag.StringVar(&asteroidDir, "asteroid-dir", "", "wiki directory location. [Mandatory, unless -vhost is used]")
 	flag.StringVar(&asteroidName, "asteroid-name", "CHANGEME", "the asteroid name (website title). read from .TITLE, or CHANGEME")
 	flag.StringVar(&themeDir, "theme-dir", "", "theme directory (css, js, img). Default is 'themes/default.theme/'")
+	flag.StringVar(&themesDir, "themes-dir", "", "directory of themes readers can pick, e.g. themes (see .asteroid.toml)")
 	flag.StringVar(&bindAddr, "bind", "0.0.0.0:8888", "server listening address")
+	flag.BoolFunc("livereload", "refresh the pages open in browsers when files change, e.g. while writing", func(s string) error {
+		b, e := strconv.ParseBool(s)
+		if b {
+			cfg.LiveReload = liveReloadPath
+		}
+		return e
+	})
+	flag.StringVar(&tlsCert, "tls-cert", "", "certificate file (PEM, e.g. fullchain.pem) to serve https and HTTP/2, reloaded when it changes. needs -tls-key")
+	flag.StringVar(&tlsKey, "tls-key", "", "private key file (PEM) of -tls-cert")
+	flag.StringVar(&httpRedirect, "http-redirect", "", "also listen on this address (e.g. :80) to redirect http requests to https. needs -tls-cert")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 15*time.Second, "on SIGINT or SIGTERM, how long requests in flight have to complete before exiting")
+	flag.DurationVar(&reloadDebounce, "reload-debounce", 300*time.Millisecond, "how long to wait for files to stop changing before reloading")
+	flag.DurationVar(&reloadMaxWait, "reload-max-wait", 5*time.Second, "reload at the latest this long after a file changed, even if files keep changing")
+	flag.Func("feed-dir", "only pages under this directory (e.g. blog) are in /feed.xml, /atom.xml and /feed.json, can be repeated", func(s string) error {
+		feedDirs = append(feedDirs, s)
+		return nil
+	})
+	flag.BoolVar(&dirIndex, "dir-index", false, "list the pages of directories having neither index.md nor README.md")
+	flag.BoolVar(&drafts, "drafts", false, "serve the pages having \"draft: true\" in their front matter, e.g. to preview them")
+	flag.BoolVar(&containSymlinks, "contain-symlinks", false, "refuse to serve the files of symlinks pointing outside of the asteroid directory")
+	flag.Func("taxonomy", "front matter list whose terms are listed at /<taxonomy>/, e.g. series, can be repeated. default is tags", func(s string) error {
+		taxonomies = append(taxonomies, s)
+		return nil
+	})
+	flag.Func("asset-ext", "extension of the files served as is (e.g. .pdf), can be repeated. default is "+strings.Join(gnAsteroid.DefaultAssetExtensions, " "), func(s string) error {
+		assetExts = append(assetExts, s)
+		return nil
+	})
+	flag.Func("download-ext", "extension of the files served as attachments (e.g. .zip), can be repeated", func(s string) error {
+		downloadExts = append(downloadExts, s)
+		return nil
+	})
+	vhosts = nil
+	flag.Func("vhost", "serve another asteroid for a Host header or a path prefix, can be repeated. e.g. 'host=blog.example.com,dir=./blog,theme=themes/raw.theme,name=Blog' or 'prefix=/wiki,dir=./wiki'", func(s string) error {
+		v, e := parseVhost(s)
+		if e == nil {
+			vhosts = append(vhosts, v)
+		}
+		return e
+	})
 	// gnoweb flags
 	flag.StringVar(&cfg.RemoteAddr, "remote", "https://rpc.gno.land:443", "remote gnoland node address")
 	flag.StringVar(&cfg.HelpChainID, "chainid", "dev", "help page's chainid")
 	flag.StringVar(&cfg.HelpRemote, "help-remote", "https://gno.land:443", "help page's remote addr")
 	flag.BoolVar(&cfg.WithAnalytics, "with-analytics", false, "enable privacy-first analytics")
+	flag.BoolVar(&cfg.ClientSideMarkdown, "client-markdown", false, "render markdown in the browser (javascript) instead of on the server")
+	flag.DurationVar(&cfg.QueryTimeout, "query-timeout", cfg.QueryTimeout, "timeout of each query to the remote gnoland node")
+	flag.IntVar(&cfg.Cache.MaxBytes, "cache-size", cfg.Cache.MaxBytes, "size in bytes of the cache of realm renders and package files, 0 to disable")
+	flag.DurationVar(&cfg.Cache.RenderTTL, "cache-render-ttl", cfg.Cache.RenderTTL, "how long realm renders are cached")
+	flag.DurationVar(&cfg.Cache.FileTTL, "cache-file-ttl", cfg.Cache.FileTTL, "how long package files and function signatures are cached")
+	flag.DurationVar(&cfg.Cache.Stale, "cache-stale", cfg.Cache.Stale, "how long expired results are still served while being refreshed")
+	flag.StringVar(&mockChainDir, "mock-chain", "", "serve realms and packages from a fixtures directory (see testdata/chain) instead of -remote, to work offline")
+	flag.StringVar(&cfg.SnapshotDir, "snapshot-dir", "", "directory where realm renders and package files are saved, to be served when the remote gnoland node is unreachable")
 	// let's parse cli
 	if parseError := flag.Parse(args); parseError != nil {
 		return cfg, parseError
 	}
-	if asteroidDir == "" {
+	if (tlsCert == "") != (tlsKey == "") {
+		return cfg, errors.New("-tls-cert and -tls-key go together")
+	} else if httpRedirect != "" && tlsCert == "" {
+		return cfg, errors.New("-http-redirect needs -tls-cert")
+	}
+	if asteroidDir == "" && len(vhosts) == 0 {
 		return cfg, errors.New("-asteroid-dir is mandatory")
-	} else if !osm.DirExists(asteroidDir) {
-		return cfg, errors.New(asteroidDir + " is not a directory")
-	} else if themeDir != "" && !osm.DirExists(themeDir) {
-		return cfg, errors.New(themeDir + " is not a directory. -theme-dir must exist, if supplied.")
+	} else if asteroidDir != "" {
+		vhosts = append(vhosts, &vhost{asteroidDir: asteroidDir, themeDir: themeDir, themesDir: themesDir, name: asteroidName, feedDirs: feedDirs, taxonomies: taxonomies, dirIndex: dirIndex, drafts: drafts, containSymlinks: containSymlinks,
+			assetExts: assetExts, downloadExts: downloadExts})
+	}
+	for _, v := range vhosts {
+		if e := v.check(logger); e != nil {
+			return cfg, e
+		}
 	}
-	// if asteroidName has default value, check whether <asteroidDir>/.TITLE exists
-	if asteroidName == "CHANGEME" && osm.FileExists(asteroidDir+"/.TITLE") {
+	sortVhosts(vhosts)
+	return cfg, nil
+}
+
+// asteroidNameFrom returns name, unless it has default value and
+// <asteroidDir>/.TITLE exists, in which case .TITLE is used.
+func asteroidNameFrom(asteroidDir, name string, logger *slog.Logger) string {
+	if (name == "CHANGEME" || name == "") && osm.FileExists(asteroidDir+"/.TITLE") {
 		s := string(osm.MustReadFile(asteroidDir + "/.TITLE"))
 		s = strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(s)
-		logger.Debug(fmt.Sprintf("asteroidName is %s and exists %s -> changing asteroidName to %q", asteroidName, asteroidDir+"/.TITLE", s))
-		asteroidName = s
+		logger.Debug(fmt.Sprintf("asteroidName is %s and exists %s -> changing asteroidName to %q", name, asteroidDir+"/.TITLE", s))
+		return s
 	}
-	gnAsteroid.SetAsteroidName(asteroidName)
-	gnAsteroid.SetAsteroidFs(os.DirFS(asteroidDir))
-	return cfg, nil
+	return name
+}
+
+// serveMockChain serves the fixtures of dir as a gnoland node on a free local port,
+// and returns its address.
+func serveMockChain(dir string, logger *slog.Logger) (string, error) {
+	if !osm.DirExists(dir) {
+		return "", fmt.Errorf("-mock-chain %s: no such directory", dir)
+	}
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		return "", e
+	}
+	go http.Serve(listener, gnAsteroid.NewMockChain(os.DirFS(dir), logger))
+	return "http://" + listener.Addr().String(), nil
+}
+
+// AsteroidFsFrom returns the FS of asteroidDir, see gnAsteroid.ContainedDirFS.
+func AsteroidFsFrom(asteroidDir string, containSymlinks bool) fs.FS {
+	if containSymlinks {
+		return gnAsteroid.ContainedDirFS(asteroidDir)
+	}
+	return os.DirFS(asteroidDir)
+}
+
+// ThemesFsFrom returns the themes of the subdirectories of themesDir,
+// named after them, without ".theme", e.g. themes/raw.theme -> "raw",
+// with the files of their parents (see gnAsteroid.InheritThemes).
+func ThemesFsFrom(themesDir string) (map[string]fs.FS, error) {
+	themes, e := installedThemes(themesDir)
+	if e != nil {
+		return nil, e
+	}
+	return gnAsteroid.InheritThemes(themes)
+}
+
+// installedThemes is ThemesFsFrom, without inheritance.
+func installedThemes(themesDir string) (map[string]fs.FS, error) {
+	entries, e := os.ReadDir(themesDir)
+	if e != nil {
+		return nil, e
+	}
+	themes := map[string]fs.FS{}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			themes[strings.TrimSuffix(entry.Name(), ".theme")] = os.DirFS(filepath.Join(themesDir, entry.Name()))
+		}
+	}
+	return themes, nil
 }
 
-func ThemeFsFrom(themeDir string) fs.FS {
+// ThemeFsFrom returns the theme of themeDir (DefaultTheme if empty), with the files
+// of its parent, installed in the same directory (see gnAsteroid.InheritTheme).
+func ThemeFsFrom(themeDir string) (fs.FS, error) {
 	if themeDir == "" {
-		return os.DirFS(gnAsteroid.DefaultTheme)
+		themeDir = gnAsteroid.DefaultTheme
+	}
+	installed, _ := installedThemes(filepath.Dir(themeDir)) // only needed with a parent
+	theme, e := gnAsteroid.InheritTheme(os.DirFS(themeDir), installed)
+	if e != nil {
+		return nil, fmt.Errorf("%s: %w", themeDir, e)
 	}
-	return os.DirFS(themeDir)
+	return theme, nil
 }
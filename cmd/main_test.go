@@ -1,6 +1,12 @@
 package main
 
 import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"log/slog"
@@ -35,3 +41,150 @@ func TestParseArgs(t *testing.T) {
 func TestAsteroidFromDir(t *testing.T) {
 	// TODO add this test
 }
+
+func TestVhosts(t *testing.T) {
+	_, e := parseArgs([]string{
+		"-asteroid-dir", "../example",
+		"-vhost", "prefix=/sub,dir=../example/subdir",
+		"-vhost", "host=svg.example.com,dir=../example/svg,theme=../themes/raw.theme,themes=../themes,name=Icons",
+		"-vhost", "host=svg.example.com,prefix=/deep,dir=../example/subdir/deep",
+	}, slog.Default())
+	require.NoError(t, e)
+	require.Len(t, vhosts, 4)
+	for _, v := range vhosts {
+		dir := v.asteroidDir
+		v.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(dir))
+		}))
+	}
+
+	for _, tc := range []struct {
+		host, path, dir string
+	}{
+		{"localhost:8888", "/", "../example"},
+		{"localhost:8888", "/sub", "../example/subdir"},
+		{"localhost:8888", "/sub/index.md", "../example/subdir"},
+		{"localhost:8888", "/subdir/", "../example"},
+		{"svg.example.com", "/", "../example/svg"},
+		{"SVG.example.com:8888", "/index.md", "../example/svg"},
+		{"svg.example.com", "/deep/blue.md", "../example/subdir/deep"},
+		{"svg.example.com", "/sub", "../example/svg"},
+	} {
+		request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		request.Host = tc.host
+		response := httptest.NewRecorder()
+		vhostRouter(vhosts).ServeHTTP(response, request)
+		require.Equal(t, tc.dir, response.Body.String(), tc.host+tc.path)
+	}
+
+	_, e = parseArgs([]string{"-vhost", "dir=../example"}, slog.Default())
+	require.Error(t, e)
+	_, e = parseArgs([]string{"-vhost", "host=a.com,dir=../nonexistent"}, slog.Default())
+	require.Error(t, e)
+	_, e = parseArgs([]string{"-vhost", "host=a.com,dir=../example,dir-index=maybe"}, slog.Default())
+	require.Error(t, e)
+	_, e = parseArgs([]string{"-vhost", "host=a.com,dir=../example,themes=../nonexistent"}, slog.Default())
+	require.Error(t, e)
+}
+
+// a real asteroid, served under a path prefix
+func TestPrefixedVhost(t *testing.T) {
+	cfg, e := parseArgs([]string{"-vhost", "prefix=/wiki,dir=../example,theme=../themes/raw.theme"}, slog.Default())
+	require.NoError(t, e)
+	require.Len(t, vhosts, 1)
+	require.NoError(t, vhosts[0].reload(slog.Default(), cfg))
+	get := func(route string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		vhostRouter(vhosts).ServeHTTP(response, httptest.NewRequest(http.MethodGet, route, nil))
+		return response
+	}
+
+	response := get("/wiki")
+	require.Equal(t, http.StatusMovedPermanently, response.Code)
+	require.Equal(t, "/wiki/", response.Header().Get("Location"))
+
+	response = get("/wiki/")
+	require.Equal(t, http.StatusOK, response.Code)
+	body := response.Body.String()
+	require.Contains(t, body, `href="/wiki/static/css/common.css"`)
+	require.Contains(t, body, `src="/wiki/static/js/highlight.min.js"`)
+	require.Contains(t, body, `href="/wiki/"`, "home")
+	require.Contains(t, body, `href="/wiki/r/demo/art/gnoface"`, "root-relative links of the content")
+	require.Contains(t, body, `href="README.md"`, "relative links resolve under /wiki/")
+
+	response = get("/wiki/static/css/common.css")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Contains(t, response.Header().Get("Content-Type"), "text/css")
+	require.Equal(t, http.StatusOK, get("/wiki/static/js/highlight.min.js").Code)
+	require.Equal(t, http.StatusOK, get("/wiki/subdir/").Code)
+	require.Equal(t, http.StatusNotFound, get("/static/css/common.css").Code, "no vhost at the root")
+	require.Equal(t, http.StatusNotFound, get("/wikipedia").Code)
+}
+
+// the options of a vhost reach the asteroid it serves
+func TestVhostOptions(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.md":       "# home",
+		"news/first.md":  "---\ndate: 2024-01-02\nseries: [Intro]\n---\n# first",
+		"other/later.md": "---\ndate: 2024-03-04\n---\n# later",
+		"draft.md":       "---\ndraft: true\n---\n# draft",
+		"files/doc.pdf":  "%PDF",
+		"files/code.zip": "PK",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	cfg, e := parseArgs([]string{"-vhost", "prefix=/wiki,dir=" + dir + ",theme=../themes/raw.theme," +
+		"feed=news,taxonomy=series,dir-index=true,asset-ext=pdf,asset-ext=zip,download-ext=zip"}, slog.Default())
+	require.NoError(t, e)
+	require.Len(t, vhosts, 1)
+	require.NoError(t, vhosts[0].reload(slog.Default(), cfg))
+	get := func(route string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		vhostRouter(vhosts).ServeHTTP(response, httptest.NewRequest(http.MethodGet, route, nil))
+		return response
+	}
+
+	response := get("/wiki/feed.xml")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Contains(t, response.Body.String(), "first")
+	require.NotContains(t, response.Body.String(), "later", "not in a feed directory")
+	response = get("/wiki/series/")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Contains(t, response.Body.String(), "Intro")
+	require.Equal(t, http.StatusNotFound, get("/wiki/tags/").Code, "taxonomies replace the default ones")
+	response = get("/wiki/files/")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Contains(t, response.Body.String(), `id="dir_index"`)
+	require.Equal(t, http.StatusNotFound, get("/wiki/draft.md").Code)
+	require.Equal(t, http.StatusOK, get("/wiki/files/doc.pdf").Code)
+	require.Contains(t, get("/wiki/files/code.zip").Header().Get("Content-Disposition"), "attachment")
+}
+
+func TestVhostWatches(t *testing.T) {
+	v := &vhost{asteroidDir: "/srv/wiki", themeDir: "/srv/theme"}
+	require.True(t, v.watches("/srv/wiki/index.md"))
+	require.True(t, v.watches("/srv/wiki/..notes.md"))
+	require.True(t, v.watches("/srv/wiki"))
+	require.False(t, v.watches("/srv/wiki-old/index.md"))
+	require.False(t, v.watches("/srv/other/index.md"))
+	require.True(t, v.isThemeCSS("/srv/theme/css/..common.css"))
+	require.False(t, v.isThemeCSS("/srv/wiki/style.css"))
+	require.False(t, v.isThemeCSS("/srv/theme/index.md"))
+}
+
+func TestThemesFsFrom(t *testing.T) {
+	themes, e := ThemesFsFrom("../themes")
+	require.NoError(t, e)
+	names := []string{}
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	require.Equal(t, []string{"bubbly", "cloudy", "raw", "readable"}, names)
+	_, e = fs.Stat(themes["raw"], "css/common.css")
+	require.NoError(t, e)
+	_, e = ThemesFsFrom("../nonexistent")
+	require.Error(t, e)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gnAsteroid/gnAsteroid"
+	"gopkg.in/fsnotify.v1"
+)
+
+// reloader reloads vhosts when their files change, or on SIGUSR1.
+// Requests are coalesced: each vhost is reloaded once, after no request
+// came for the debounce window, e.g. when a `git checkout` is over,
+// or at the latest maxWait after the first request, e.g. if files keep changing.
+type reloader struct {
+	logger   *slog.Logger
+	cfg      *gnAsteroid.Config
+	debounce time.Duration
+	maxWait  time.Duration
+	// if not nil, called after each reload, cssOnly if only css files
+	// of the theme changed, e.g. to refresh open pages (see liveReload)
+	reloaded func(v *vhost, cssOnly bool, e error)
+
+	mu      sync.Mutex
+	pending map[*vhost]bool // vhost -> css only
+	wake    chan struct{}   // signals run that pending changed, one signal is enough
+}
+
+func newReloader(logger *slog.Logger, cfg *gnAsteroid.Config, debounce, maxWait time.Duration) *reloader {
+	return &reloader{logger: logger, cfg: cfg, debounce: debounce, maxWait: maxWait, pending: map[*vhost]bool{}, wake: make(chan struct{}, 1)}
+}
+
+// request asks for v to be reloaded, because the file name changed ("" if unknown).
+// It doesn't block, e.g. the watcher while a reload is in progress.
+func (rl *reloader) request(v *vhost, name string) {
+	rl.mu.Lock()
+	cssOnly, seen := rl.pending[v]
+	rl.pending[v] = (cssOnly || !seen) && v.isThemeCSS(name)
+	rl.mu.Unlock()
+	select {
+	case rl.wake <- struct{}{}:
+	default: // already signaled
+	}
+}
+
+// run reloads the requested vhosts, until stop is closed.
+func (rl *reloader) run(stop <-chan struct{}) {
+	var fire, deadline <-chan time.Time // nil until a request comes
+	for {
+		select {
+		case <-rl.wake:
+			fire = time.After(rl.debounce)
+			if deadline == nil {
+				deadline = time.After(rl.maxWait)
+			}
+		case <-fire:
+			rl.reloadPending()
+			fire, deadline = nil, nil
+		case <-deadline:
+			rl.reloadPending()
+			fire, deadline = nil, nil
+		case <-stop:
+			return
+		}
+	}
+}
+
+// reloadPending reloads the requested vhosts.
+func (rl *reloader) reloadPending() {
+	rl.mu.Lock()
+	pending := rl.pending
+	rl.pending = map[*vhost]bool{}
+	rl.mu.Unlock()
+	for v, cssOnly := range pending {
+		e := v.reload(rl.logger, rl.cfg)
+		if e != nil {
+			rl.logger.Error("Reloading "+v.asteroidDir+" failed, still serving the previous version", "error", e)
+		} else {
+			rl.logger.Info("Reloaded " + v.asteroidDir)
+		}
+		if rl.reloaded != nil {
+			rl.reloaded(v, cssOnly, e)
+		}
+	}
+}
+
+// watcher watches directories recursively, including the subdirectories
+// created after it started. .git directories are not watched.
+type watcher struct {
+	logger *slog.Logger
+	fs     *fsnotify.Watcher
+}
+
+func newWatcher(logger *slog.Logger) (*watcher, error) {
+	w, e := fsnotify.NewWatcher()
+	if e != nil {
+		return nil, e
+	}
+	return &watcher{logger: logger, fs: w}, nil
+}
+
+// addRecursive watches dir and its subdirectories.
+func (w *watcher) addRecursive(dir string) error {
+	return w.walk(dir, nil)
+}
+
+// add watches dir, but not its subdirectories.
+func (w *watcher) add(dir string) error {
+	return w.fs.Add(dir)
+}
+
+// walk watches dir and its subdirectories, calling found (if not nil)
+// with the files and subdirectories it contains.
+func (w *watcher) walk(dir string, found func(name string)) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, e error) error {
+		if e != nil {
+			if errors.Is(e, fs.ErrNotExist) {
+				return nil // removed meanwhile
+			}
+			return e
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if found != nil && p != dir {
+			found(p)
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return w.fs.Add(p)
+	})
+}
+
+// run calls changed with the name of each changed file, until the watcher is closed.
+func (w *watcher) run(changed func(name string)) {
+	for {
+		select {
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			changed(event.Name)
+			if event.Op&fsnotify.Create != 0 {
+				if stat, e := os.Stat(event.Name); e == nil && stat.IsDir() {
+					// files may have been created in it before it is watched
+					if e := w.walk(event.Name, changed); e != nil {
+						w.logger.Error("watching "+event.Name, "error", e)
+					}
+				}
+			}
+		case e, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Error("watcher", "error", e)
+		}
+	}
+}
+
+func (w *watcher) Close() error {
+	return w.fs.Close()
+}
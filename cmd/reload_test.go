@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnAsteroid/gnAsteroid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testVhost(t *testing.T) *vhost {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.md"), []byte("# Version 1"), 0o644))
+	v := &vhost{asteroidDir: dir, name: "Reload", themeDir: "../" + gnAsteroid.DefaultTheme}
+	require.NoError(t, v.reload(slog.Default(), gnAsteroid.NewDefaultConfig()))
+	return v
+}
+
+func getHome(v *vhost) string {
+	response := httptest.NewRecorder()
+	vhostRouter{v}.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+	return response.Body.String()
+}
+
+func TestReloaderDebounce(t *testing.T) {
+	v := testVhost(t)
+	rl := newReloader(slog.Default(), gnAsteroid.NewDefaultConfig(), 50*time.Millisecond, time.Second)
+	reloads := make(chan error, 10)
+	rl.reloaded = func(_ *vhost, _ bool, e error) { reloads <- e }
+	stop := make(chan struct{})
+	defer close(stop)
+	go rl.run(stop)
+
+	require.NoError(t, os.WriteFile(filepath.Join(v.asteroidDir, "index.md"), []byte("# Version 2"), 0o644))
+	for i := 0; i < 20; i++ {
+		rl.request(v, "")
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case e := <-reloads:
+		require.NoError(t, e)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reloaded")
+	}
+	assert.Contains(t, getHome(v), "Version 2")
+	select {
+	case <-reloads:
+		t.Fatal("reloaded more than once")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestReloaderMaxWait(t *testing.T) {
+	v := testVhost(t)
+	rl := newReloader(slog.Default(), gnAsteroid.NewDefaultConfig(), 50*time.Millisecond, 200*time.Millisecond)
+	reloads := make(chan error, 10)
+	rl.reloaded = func(_ *vhost, _ bool, e error) { reloads <- e }
+	stop := make(chan struct{})
+	defer close(stop)
+	go rl.run(stop)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() { // files keep changing, faster than the debounce window
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				rl.request(v, "")
+			}
+		}
+	}()
+	select {
+	case e := <-reloads:
+		require.NoError(t, e)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reloaded before maxWait")
+	}
+}
+
+func TestReloadError(t *testing.T) {
+	v := testVhost(t)
+	require.NoError(t, os.Remove(filepath.Join(v.asteroidDir, "index.md")))
+	assert.ErrorIs(t, v.reload(slog.Default(), gnAsteroid.NewDefaultConfig()), gnAsteroid.ErrNoIndex)
+	// still serving the previous handler
+	assert.Contains(t, getHome(v), "Version 1")
+}
+
+// an asteroid without index.md from the start is served, but its root
+func TestReloadNoIndex(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "page.md"), []byte("# still here"), 0o644))
+	v := &vhost{asteroidDir: dir, name: "Reload", themeDir: "../" + gnAsteroid.DefaultTheme}
+	assert.ErrorIs(t, v.reload(slog.Default(), gnAsteroid.NewDefaultConfig()), gnAsteroid.ErrNoIndex)
+	get := func(route string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		vhostRouter{v}.ServeHTTP(response, httptest.NewRequest(http.MethodGet, route, nil))
+		return response
+	}
+	assert.Equal(t, http.StatusInternalServerError, get("/").Code)
+	assert.Contains(t, get("/page.md").Body.String(), "still here")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.md"), []byte("# Version 1"), 0o644))
+	require.NoError(t, v.reload(slog.Default(), gnAsteroid.NewDefaultConfig()))
+	assert.Contains(t, getHome(v), "Version 1")
+}
+
+func TestWatcherNewSubdirs(t *testing.T) {
+	dir := t.TempDir()
+	w, e := newWatcher(slog.Default())
+	require.NoError(t, e)
+	defer w.Close()
+	require.NoError(t, w.addRecursive(dir))
+	changed := make(chan string, 100)
+	go w.run(func(name string) { changed <- name })
+
+	sub := filepath.Join(dir, "new", "deep")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+	// wait for the new directories to be watched
+	deadline := time.After(5 * time.Second)
+	for seen := false; !seen; {
+		select {
+		case name := <-changed:
+			seen = name == sub
+		case <-deadline:
+			t.Fatal("new directory not seen")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	page := filepath.Join(sub, "page.md")
+	require.NoError(t, os.WriteFile(page, []byte("# Page"), 0o644))
+	for {
+		select {
+		case name := <-changed:
+			if name == page {
+				return
+			}
+		case <-deadline:
+			t.Fatal("file in a new directory not seen")
+		}
+	}
+}
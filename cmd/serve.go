@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serve serves server on listener until ctx is done (e.g. on SIGTERM),
+// then shuts it down gracefully: requests in flight have up to drain to complete.
+// If server.TLSConfig is set, it serves https, and HTTP/2.
+func serve(ctx context.Context, logger *slog.Logger, server *http.Server, listener net.Listener, drain time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			served <- server.ServeTLS(listener, "", "")
+		} else {
+			served <- server.Serve(listener)
+		}
+	}()
+	select {
+	case e := <-served:
+		return e
+	case <-ctx.Done():
+	}
+	logger.Info(fmt.Sprintf("Shutting down %s, waiting up to %s for requests in flight", listener.Addr(), drain))
+	shutdown, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if e := server.Shutdown(shutdown); e != nil {
+		server.Close()
+		return fmt.Errorf("shutting down: %w", e)
+	}
+	if e := <-served; !errors.Is(e, http.ErrServerClosed) {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServe serves server with serve, on a random port.
+func startServe(t *testing.T, server *http.Server, drain time.Duration) (url string, cancel func(), served chan error) {
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, e)
+	ctx, cancel := context.WithCancel(context.Background())
+	served = make(chan error, 1)
+	go func() {
+		served <- serve(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), server, listener, drain)
+	}()
+	return "http://" + listener.Addr().String(), cancel, served
+}
+
+func TestServeDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	url, cancel, served := startServe(t, &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})}, 5*time.Second)
+
+	got := make(chan string, 1)
+	go func() {
+		response, e := http.Get(url)
+		if e != nil {
+			got <- e.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		got <- string(body)
+	}()
+	<-started
+	cancel()
+	assert.Equal(t, "done", <-got, "the request in flight completes")
+	select {
+	case e := <-served:
+		assert.NoError(t, e)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shut down")
+	}
+	_, e := http.Get(url)
+	assert.Error(t, e, "new connections are refused")
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	url, cancel, served := startServe(t, &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}, 100*time.Millisecond)
+
+	go http.Get(url)
+	<-started
+	cancel()
+	select {
+	case e := <-served:
+		assert.ErrorIs(t, e, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the drain timeout was not enforced")
+	}
+}
+
+func TestServeClosesLiveReload(t *testing.T) {
+	lr := newLiveReload([]*vhost{{}})
+	server := &http.Server{Handler: lr.handler(http.NotFoundHandler())}
+	server.RegisterOnShutdown(lr.close)
+	url, cancel, served := startServe(t, server, 5*time.Second)
+	response, e := http.Get(url + liveReloadPath)
+	require.NoError(t, e)
+	defer response.Body.Close()
+
+	cancel()
+	select {
+	case e := <-served:
+		assert.NoError(t, e, "event streams don't hold the shutdown")
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shut down")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/gnAsteroid/gnAsteroid"
+	osm "github.com/gnolang/gno/tm2/pkg/os"
+)
+
+// gnAsteroid theme check <theme-dir>...
+//
+// Validates themes (see gnAsteroid.CheckTheme), exits with 1 on problems.
+func theme(args []string, logger *slog.Logger) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New("usage: gnAsteroid theme check <theme-dir>...")
+	}
+	flag := flag.NewFlagSet("theme check", flag.ContinueOnError)
+	if e := flag.Parse(args[1:]); e != nil {
+		return e
+	} else if flag.NArg() == 0 {
+		return errors.New("usage: gnAsteroid theme check <theme-dir>...")
+	}
+	count := 0
+	for _, themeDir := range flag.Args() {
+		if !osm.DirExists(themeDir) {
+			return errors.New(themeDir + " is not a directory")
+		}
+		themeFs, e := ThemeFsFrom(themeDir)
+		if e != nil {
+			return e
+		}
+		problems := gnAsteroid.CheckTheme(themeFs)
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", themeDir, problem)
+		}
+		if len(problems) == 0 {
+			logger.Info(themeDir + " is valid")
+		}
+		count += len(problems)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d problem(s)", count)
+	}
+	return nil
+}
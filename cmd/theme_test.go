@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTheme(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		name = filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	}
+	write("base.theme/css/common.css", "base css")
+	write("child.theme/theme.toml", `parent = "base"`)
+	write("broken.theme/theme.toml", `parent = "base"`)
+	write("broken.theme/views/404.html", `{{ define "app" }}{{ end }}`)
+	write("orphan.theme/theme.toml", `parent = "nope"`)
+
+	themeFs, e := ThemeFsFrom(filepath.Join(dir, "child.theme"))
+	require.NoError(t, e)
+	content, e := fs.ReadFile(themeFs, "css/common.css")
+	require.NoError(t, e)
+	require.Equal(t, "base css", string(content), "inherited")
+	themes, e := installedThemes(dir)
+	require.NoError(t, e)
+	require.Len(t, themes, 4)
+	_, e = ThemesFsFrom(dir)
+	require.ErrorContains(t, e, "orphan")
+
+	require.NoError(t, theme([]string{"check", filepath.Join(dir, "base.theme"), filepath.Join(dir, "child.theme")}, slog.Default()))
+	require.EqualError(t, theme([]string{"check", filepath.Join(dir, "broken.theme")}, slog.Default()), "1 problem(s)")
+	require.ErrorContains(t, theme([]string{"check", filepath.Join(dir, "orphan.theme")}, slog.Default()), "not installed")
+	require.Error(t, theme([]string{"check"}, slog.Default()))
+	require.Error(t, theme([]string{"lint", filepath.Join(dir, "base.theme")}, slog.Default()))
+	require.Error(t, theme([]string{"check", filepath.Join(dir, "nope.theme")}, slog.Default()))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certificate is the -tls-cert and -tls-key pair, reloaded when they change
+// (e.g. renewed by certbot) without restarting the server.
+type certificate struct {
+	certFile, keyFile string
+	mu                sync.RWMutex
+	cert              *tls.Certificate
+	pending           *time.Timer // see reloadAfter
+}
+
+func loadCertificate(certFile, keyFile string) (*certificate, error) {
+	c := &certificate{certFile: certFile, keyFile: keyFile}
+	return c, c.reload()
+}
+
+// reload reads the certificate and key again. On error, the previous ones are kept.
+func (c *certificate) reload() error {
+	cert, e := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if e != nil {
+		return fmt.Errorf("loading -tls-cert %s and -tls-key %s: %w", c.certFile, c.keyFile, e)
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.mu.Unlock()
+	return nil
+}
+
+// reloadAfter reloads the certificate once its files stopped changing for debounce,
+// as they are usually not written at once.
+func (c *certificate) reloadAfter(debounce time.Duration, logger *slog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending != nil {
+		c.pending.Stop()
+	}
+	c.pending = time.AfterFunc(debounce, func() {
+		if e := c.reload(); e != nil {
+			logger.Error("Reloading the certificate failed, still serving the previous one", "error", e)
+		} else {
+			logger.Info("Reloaded " + c.certFile)
+		}
+	})
+}
+
+// GetCertificate is for tls.Config.
+func (c *certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// dirs returns the directories to watch for changes of the certificate or key.
+func (c *certificate) dirs() []string {
+	dirs := []string{filepath.Dir(c.certFile)}
+	if dir := filepath.Dir(c.keyFile); dir != dirs[0] {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// watches tells whether filename is the certificate or the key.
+func (c *certificate) watches(filename string) bool {
+	filename = filepath.Clean(filename)
+	return filename == filepath.Clean(c.certFile) || filename == filepath.Clean(c.keyFile)
+}
+
+// tlsConfig serves c. HTTP/2 is negotiated by http.Server.ServeTLS.
+func (c *certificate) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: c.GetCertificate,
+	}
+}
+
+// redirectToHTTPS redirects requests to the same URL, in https on the port of httpsAddr.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, e := net.SplitHostPort(r.Host)
+		if e != nil { // no port
+			host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
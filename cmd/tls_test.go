@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate for localhost, of serial number serial.
+func writeCertificate(t *testing.T, certFile, keyFile string, serial int64) {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, e)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, e := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, e)
+	keyDer, e := x509.MarshalECPrivateKey(key)
+	require.NoError(t, e)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "fullchain.pem"), filepath.Join(dir, "privkey.pem")
+	writeCertificate(t, certFile, keyFile, 1)
+	cert, e := loadCertificate(certFile, keyFile)
+	require.NoError(t, e)
+	assert.True(t, cert.watches(dir+"/./fullchain.pem"))
+	assert.False(t, cert.watches(filepath.Join(dir, "chain.pem")))
+	assert.Equal(t, []string{dir}, cert.dirs())
+
+	server := &http.Server{TLSConfig: cert.tlsConfig(), Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})}
+	url, cancel, served := startServe(t, server, time.Second)
+	defer func() {
+		cancel()
+		<-served
+	}()
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	get := func() *http.Response {
+		client.CloseIdleConnections() // for a new handshake
+		response, e := client.Get("https" + url[len("http"):])
+		require.NoError(t, e)
+		response.Body.Close()
+		return response
+	}
+	response := get()
+	assert.Equal(t, 2, response.ProtoMajor, "HTTP/2 is negotiated")
+	assert.Equal(t, int64(1), response.TLS.PeerCertificates[0].SerialNumber.Int64())
+
+	// renewed
+	writeCertificate(t, certFile, keyFile, 2)
+	require.NoError(t, cert.reload())
+	assert.Equal(t, int64(2), get().TLS.PeerCertificates[0].SerialNumber.Int64())
+
+	writeCertificate(t, certFile, keyFile, 3)
+	cert.reloadAfter(10*time.Millisecond, slog.Default())
+	cert.reloadAfter(10*time.Millisecond, slog.Default())
+	assert.Eventually(t, func() bool {
+		return get().TLS.PeerCertificates[0].SerialNumber.Int64() == 3
+	}, time.Second, 20*time.Millisecond)
+
+	// a broken certificate is not served
+	require.NoError(t, os.WriteFile(keyFile, []byte("oops"), 0o600))
+	assert.Error(t, cert.reload())
+	assert.Equal(t, int64(3), get().TLS.PeerCertificates[0].SerialNumber.Int64())
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	for _, test := range []struct{ addr, host, location string }{
+		{":443", "example.com", "https://example.com/a/b?c=d"},
+		{"0.0.0.0:443", "example.com:80", "https://example.com/a/b?c=d"},
+		{":8443", "example.com:8080", "https://example.com:8443/a/b?c=d"},
+		{":443", "[::1]:80", "https://[::1]/a/b?c=d"},
+		{":8443", "[::1]", "https://[::1]:8443/a/b?c=d"},
+	} {
+		request := httptest.NewRequest("GET", "http://"+test.host+"/a/b?c=d", nil)
+		response := httptest.NewRecorder()
+		redirectToHTTPS(test.addr).ServeHTTP(response, request)
+		assert.Equal(t, http.StatusPermanentRedirect, response.Code)
+		assert.Equal(t, test.location, response.Header().Get("Location"), test)
+	}
+}
+
+func TestTLSArgs(t *testing.T) {
+	_, e := parseArgs([]string{"-asteroid-dir", "../example", "-tls-cert", "cert.pem"}, slog.Default())
+	assert.Error(t, e, "no -tls-key")
+	_, e = parseArgs([]string{"-asteroid-dir", "../example", "-http-redirect", ":80"}, slog.Default())
+	assert.Error(t, e, "no -tls-cert")
+	_, e = parseArgs([]string{"-asteroid-dir", "../example", "-tls-cert", "cert.pem", "-tls-key", "key.pem", "-http-redirect", ":80"}, slog.Default())
+	assert.NoError(t, e)
+	assert.Equal(t, "cert.pem", tlsCert)
+	assert.Equal(t, ":80", httpRedirect)
+}
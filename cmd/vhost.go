@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gnAsteroid/gnAsteroid"
+	osm "github.com/gnolang/gno/tm2/pkg/os"
+)
+
+// vhost maps a Host header, or a path prefix, to an asteroid.
+// The asteroid given with -asteroid-dir is a vhost with neither host nor prefix:
+// it gets every request the other vhosts don't match.
+type vhost struct {
+	host            string // e.g. "blog.example.com", matched against the Host header (port excluded)
+	prefix          string // e.g. "/blog", matched against the request path
+	asteroidDir     string
+	themeDir        string
+	themesDir       string // directory of the themes readers can pick, see ThemesFsFrom
+	name            string
+	feedDirs        []string // see gnAsteroid.Asteroid.FeedDirs
+	taxonomies      []string // see gnAsteroid.Asteroid.Taxonomies
+	dirIndex        bool     // see gnAsteroid.Asteroid.DirIndex
+	drafts          bool     // see gnAsteroid.Asteroid.Drafts
+	containSymlinks bool     // see gnAsteroid.ContainedDirFS
+	assetExts       []string // see gnAsteroid.Asteroid.AssetExtensions
+	downloadExts    []string // see gnAsteroid.Asteroid.DownloadExtensions
+
+	chain    *gnAsteroid.ChainClient // shared by the vhosts, see gnAsteroid.Asteroid.Chain
+	asteroid *gnAsteroid.Asteroid
+	handler  atomic.Pointer[http.Handler] // swapped by reload while serving
+}
+
+// parseVhost parses a -vhost flag value, a comma-separated list of key=value:
+//
+//	host=blog.example.com,dir=./blog,theme=themes/raw.theme,themes=themes,name=My Blog
+//	prefix=/wiki,dir=./wiki,feed=news,feed=blog,taxonomy=tags,taxonomy=series,dir-index=true,drafts=true
+//	host=files.example.com,dir=./files,asset-ext=.pdf,asset-ext=.epub,download-ext=.epub,contain-symlinks=true
+//
+// dir and one of host or prefix are mandatory. feed, taxonomy, asset-ext and download-ext can be repeated.
+func parseVhost(s string) (*vhost, error) {
+	v := &vhost{}
+	for _, kv := range strings.Split(s, ",") {
+		k, val, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("-vhost %q: expected key=value, got %q", s, kv)
+		}
+		switch k = strings.TrimSpace(k); k {
+		case "host":
+			v.host = strings.ToLower(strings.TrimSpace(val))
+		case "prefix":
+			v.prefix = "/" + strings.Trim(strings.TrimSpace(val), "/")
+		case "dir":
+			v.asteroidDir = strings.TrimSpace(val)
+		case "theme":
+			v.themeDir = strings.TrimSpace(val)
+		case "themes":
+			v.themesDir = strings.TrimSpace(val)
+		case "name":
+			v.name = val
+		case "feed":
+			v.feedDirs = append(v.feedDirs, strings.TrimSpace(val))
+		case "taxonomy":
+			v.taxonomies = append(v.taxonomies, strings.TrimSpace(val))
+		case "dir-index", "drafts", "contain-symlinks":
+			b, e := strconv.ParseBool(strings.TrimSpace(val))
+			if e != nil {
+				return nil, fmt.Errorf("-vhost %q: %s: %w", s, k, e)
+			}
+			switch k {
+			case "dir-index":
+				v.dirIndex = b
+			case "drafts":
+				v.drafts = b
+			default:
+				v.containSymlinks = b
+			}
+		case "asset-ext":
+			v.assetExts = append(v.assetExts, strings.TrimSpace(val))
+		case "download-ext":
+			v.downloadExts = append(v.downloadExts, strings.TrimSpace(val))
+		default:
+			return nil, fmt.Errorf("-vhost %q: unknown key %q", s, k)
+		}
+	}
+	if v.asteroidDir == "" {
+		return nil, fmt.Errorf("-vhost %q: dir is mandatory", s)
+	} else if v.host == "" && v.prefix == "" {
+		return nil, fmt.Errorf("-vhost %q: host or prefix is mandatory", s)
+	} else if v.prefix == "/" {
+		return nil, fmt.Errorf("-vhost %q: prefix can not be /, use -asteroid-dir instead", s)
+	}
+	return v, nil
+}
+
+// check verifies directories exist and names the asteroid.
+func (v *vhost) check(logger *slog.Logger) error {
+	if !osm.DirExists(v.asteroidDir) {
+		return errors.New(v.asteroidDir + " is not a directory")
+	} else if v.themeDir != "" && !osm.DirExists(v.themeDir) {
+		return errors.New(v.themeDir + " is not a directory. theme directories must exist, if supplied.")
+	} else if v.themesDir != "" && !osm.DirExists(v.themesDir) {
+		return errors.New(v.themesDir + " is not a directory. theme directories must exist, if supplied.")
+	}
+	v.name = asteroidNameFrom(v.asteroidDir, v.name, logger)
+	if v.name == "" {
+		v.name = filepath.Base(v.asteroidDir)
+	}
+	return nil
+}
+
+// reload (re)builds the vhost handler, e.g. after files changed on disk.
+// On error, the previous handler is kept. Without previous handler, an asteroid
+// without index (gnAsteroid.ErrNoIndex) is served nonetheless, with an error page at its root.
+func (v *vhost) reload(logger *slog.Logger, cfg *gnAsteroid.Config) error {
+	if v.asteroid == nil {
+		v.asteroid = &gnAsteroid.Asteroid{
+			FS:     AsteroidFsFrom(v.asteroidDir, v.containSymlinks),
+			Name:   v.name,
+			Prefix: v.prefix,
+			Config: cfg,
+			Chain:  v.chain,
+			Logger: logger,
+
+			FeedDirs:   v.feedDirs,
+			Taxonomies: v.taxonomies,
+			DirIndex:   v.dirIndex,
+			Drafts:     v.drafts,
+
+			AssetExtensions:    v.assetExts,
+			DownloadExtensions: v.downloadExts,
+		}
+	}
+	theme, e := ThemeFsFrom(v.themeDir)
+	if e != nil {
+		return fmt.Errorf("reloading %s: %w", v.asteroidDir, e)
+	}
+	v.asteroid.ThemeFS = theme // e.g. its parent changed
+	if v.themesDir != "" {
+		themes, e := ThemesFsFrom(v.themesDir)
+		if e != nil {
+			return fmt.Errorf("reloading %s: %w", v.asteroidDir, e)
+		}
+		v.asteroid.Themes = themes // e.g. a theme was added
+	}
+	handler, e := v.asteroid.NewHandler()
+	if e != nil {
+		// e.g. the asteroid has no index.md anymore: unless it never had one,
+		// the previous handler serves its index
+		if handler != nil && errors.Is(e, gnAsteroid.ErrNoIndex) && v.handler.Load() == nil {
+			v.setHandler(handler)
+		}
+		return fmt.Errorf("reloading %s: %w", v.asteroidDir, e)
+	}
+	v.setHandler(handler)
+	return nil
+}
+
+// setHandler atomically replaces the handler of v.
+func (v *vhost) setHandler(handler http.Handler) {
+	v.handler.Store(&handler)
+}
+
+// watches tells whether a changed file (as reported by the watcher) belongs to this vhost.
+func (v *vhost) watches(filename string) bool {
+	for _, dir := range []string{v.asteroidDir, v.themeDir, v.themesDir} {
+		if dir == "" {
+			continue
+		}
+		if inDir(dir, filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// isThemeCSS tells whether a changed file is a css file of the themes of this vhost.
+func (v *vhost) isThemeCSS(filename string) bool {
+	if !strings.HasSuffix(filename, ".css") {
+		return false
+	}
+	for _, dir := range []string{v.themeDir, v.themesDir} {
+		if dir == "" {
+			continue
+		}
+		if inDir(dir, filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDir tells whether filename is dir or in it, e.g. "..notes.md" is, "../notes.md" isn't.
+func inDir(dir, filename string) bool {
+	rel, e := filepath.Rel(dir, filename)
+	return e == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// matches tells whether the request should be served by this vhost.
+func (v *vhost) matches(r *http.Request) bool {
+	if v.host != "" {
+		host, _, e := net.SplitHostPort(r.Host)
+		if e != nil {
+			host = r.Host
+		}
+		if !strings.EqualFold(host, v.host) {
+			return false
+		}
+	}
+	if v.prefix != "" {
+		return r.URL.Path == v.prefix || strings.HasPrefix(r.URL.Path, v.prefix+"/")
+	}
+	return true
+}
+
+// vhostRouter serves each request with the first matching vhost.
+type vhostRouter []*vhost
+
+func (vhosts vhostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, v := range vhosts {
+		if v.matches(r) {
+			if handler := v.handler.Load(); handler != nil {
+				(*handler).ServeHTTP(w, r)
+			} else {
+				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			}
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// sortVhosts orders vhosts from the most specific to the least specific,
+// e.g. host and prefix, then host, then the longest prefixes, then the default asteroid.
+func sortVhosts(vhosts []*vhost) {
+	specificity := func(v *vhost) int {
+		n := len(v.prefix)
+		if v.host != "" {
+			n += 1 << 16
+		}
+		return n
+	}
+	sort.SliceStable(vhosts, func(i, j int) bool {
+		return specificity(vhosts[i]) > specificity(vhosts[j])
+	})
+}
@@ -0,0 +1,162 @@
+package gnAsteroid
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gotuna/gotuna"
+)
+
+// Directory indexes: when Asteroid.DirIndex is set, a directory having
+// neither index.md nor README.md is served as a listing of its
+// subdirectories and markdown pages, the root included, sortable with ?sort=name, title or
+// date, "-" first for the reverse order (e.g. ?sort=-date).
+
+// dirIndexSorts are the accepted ?sort= columns, the first one being the default.
+var dirIndexSorts = []string{"name", "title", "date"}
+
+type dirEntry struct {
+	Name        string // file name, e.g. "post.md", or "sub/" for a directory
+	URL         string
+	Title       string
+	Description string
+	Date        time.Time // zero if the page has no date
+	IsDir       bool
+}
+
+// hasIndex tells whether the directory dir is served by its index.md or README.md.
+func hasIndex(asteroid fs.FS, dir string) bool {
+	for _, file := range []string{"index.md", "README.md"} {
+		if stat, e := fs.Stat(asteroid, path.Join(dir, file)); e == nil && !stat.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// readDirEntries lists the subdirectories and markdown pages of dir, dotfiles excepted,
+// linked under base (see basePath).
+func (a *Asteroid) readDirEntries(dir, base string) ([]dirEntry, error) {
+	files, e := fs.ReadDir(a.FS, dir)
+	if e != nil {
+		return nil, e
+	}
+	var entries []dirEntry
+	for _, file := range files {
+		name := file.Name()
+		p := path.Join(dir, name)
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		entry := dirEntry{Name: name, URL: base + "/" + p, Title: name, IsDir: file.IsDir()}
+		if file.IsDir() {
+			entry.Name += "/"
+			entry.URL += "/"
+			// a directory is described by its index page, if any
+			for _, index := range []string{"index.md", "README.md"} {
+				if content, e := fs.ReadFile(a.FS, path.Join(p, index)); e == nil {
+					_, fm := frontMatterOf(a.logger(), path.Join(p, index), string(content))
+					entry.describe(fm)
+					break
+				}
+			}
+		} else if strings.HasSuffix(name, ".md") {
+			entry.Title = strings.TrimSuffix(name, ".md")
+			content, e := fs.ReadFile(a.FS, p)
+			if e != nil {
+				a.logger().Error("reading page", "file", p, "error", e)
+				continue
+			}
+			_, fm := frontMatterOf(a.logger(), p, string(content))
+			entry.describe(fm)
+		} else {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// describe sets the title, description and date of e from front matter.
+func (e *dirEntry) describe(fm FrontMatter) {
+	if title := fm.String("title"); title != "" {
+		e.Title = title
+	}
+	e.Description = fm.String("description")
+	e.Date, _ = fm.Time("date")
+}
+
+// sortDirEntries sorts entries by column ("name", "title" or "date"),
+// in reverse order if desc. Directories come first.
+func sortDirEntries(entries []dirEntry, column string, desc bool) {
+	less := func(a, b dirEntry) bool {
+		switch column {
+		case "title":
+			if ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title); ta != tb {
+				return ta < tb
+			}
+		case "date":
+			if !a.Date.Equal(b.Date) {
+				return a.Date.Before(b.Date)
+			}
+		}
+		return a.Name < b.Name
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		if desc {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// parseDirIndexSort parses ?sort=, e.g. "-date", defaulting to "name".
+func parseDirIndexSort(s string) (column string, desc bool) {
+	column, desc = strings.CutPrefix(s, "-")
+	for _, valid := range dirIndexSorts {
+		if column == valid {
+			return column, desc
+		}
+	}
+	return dirIndexSorts[0], false
+}
+
+// serveDirIndex serves the listing of dir (e.g. "blog/2024", or "." for the root).
+func (a *Asteroid) serveDirIndex(w http.ResponseWriter, r *http.Request, app gotuna.App, cfg *Config, dir string) {
+	entries, e := a.readDirEntries(dir, basePath(r))
+	if e != nil {
+		renderError(a.logger(), app, cfg, w, r, http.StatusInternalServerError, e)
+		return
+	}
+	column, desc := parseDirIndexSort(r.URL.Query().Get("sort"))
+	sortDirEntries(entries, column, desc)
+	// each column header links to its sort, or to the reverse order if already sorted by it
+	sortLinks := map[string]string{}
+	for _, c := range dirIndexSorts {
+		sortLinks[c] = "?sort=" + c
+		if c == column && !desc {
+			sortLinks[c] = "?sort=-" + c
+		}
+	}
+	pageName, atHome := dir+"/", "0"
+	if dir == "." {
+		pageName, atHome = a.Name, "1"
+	}
+	app.NewTemplatingEngine().
+		Set("AsteroidName", a.Name).
+		Set("AtHome", atHome).
+		Set("PageName", pageName).
+		Set("Entries", entries).
+		Set("Sort", column).
+		Set("Desc", desc).
+		Set("SortLinks", sortLinks).
+		Set("Config", cfg).
+		Render(w, r, "asteroid_dir.html", "funcs.html")
+}
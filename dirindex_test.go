@@ -0,0 +1,80 @@
+package gnAsteroid
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dirIndexFS = fstest.MapFS{
+	"index.md":            {Data: []byte("# Home")},
+	"notes/b.md":          {Data: []byte("---\ntitle: Beta\ndate: 2024-01-01\ndescription: The second letter.\n---\nB")},
+	"notes/a.md":          {Data: []byte("---\ntitle: Zeta\ndate: 2024-06-01\n---\nZ")},
+	"notes/c.md":          {Data: []byte("No front matter.")},
+	"notes/img.png":       {Data: []byte("png")},
+	"notes/.draft.md":     {Data: []byte("Hidden.")},
+	"notes/sub/README.md": {Data: []byte("---\ntitle: Sub notes\n---\n")},
+	"notes/.hidden/x.md":  {Data: []byte("Hidden.")},
+	"withindex/index.md":  {Data: []byte("# Index")},
+	"withindex/other.md":  {Data: []byte("# Other")},
+}
+
+func TestDirIndex(t *testing.T) {
+	names := func(body string) []string {
+		var names []string
+		for _, m := range regexp.MustCompile(`<td class="dir_name"><a href="[^"]*">([^<]*)</a>`).FindAllStringSubmatch(body, -1) {
+			names = append(names, m[1])
+		}
+		return names
+	}
+	asteroid := NewAsteroid(dirIndexFS, "Index")
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), "/notes/", nil).Code)
+
+	asteroid.DirIndex = true
+	response := serveRoute(t, asteroid.Handler(), "/notes/", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	body := response.Body.String()
+	assert.Equal(t, []string{"sub/", "a.md", "b.md", "c.md"}, names(body))
+	assert.Contains(t, body, `<a href="/notes/sub/">sub/</a></td>`)
+	assert.Contains(t, body, `<td class="dir_title">Sub notes</td>`)
+	assert.Contains(t, body, `<td class="dir_title">Beta<p class="dir_description">The second letter.</p></td>`)
+	assert.Contains(t, body, `<td class="dir_title">c</td>`)
+	assert.Contains(t, body, `<th class="dir_name" aria-sort="ascending"><a href="?sort=-name">name</a></th>`)
+	assert.Contains(t, body, `<th class="dir_date"><a href="?sort=date">date</a></th>`)
+
+	body = serveRoute(t, asteroid.Handler(), "/notes/?sort=title", nil).Body.String()
+	assert.Equal(t, []string{"sub/", "b.md", "c.md", "a.md"}, names(body))
+	body = serveRoute(t, asteroid.Handler(), "/notes/?sort=-date", nil).Body.String()
+	assert.Equal(t, []string{"sub/", "a.md", "b.md", "c.md"}, names(body))
+	assert.Contains(t, body, `<th class="dir_date" aria-sort="descending"><a href="?sort=date">date</a></th>`)
+	body = serveRoute(t, asteroid.Handler(), "/notes/?sort=nonsense", nil).Body.String()
+	assert.Equal(t, []string{"sub/", "a.md", "b.md", "c.md"}, names(body))
+
+	assert.Contains(t, serveRoute(t, asteroid.Handler(), "/withindex/", nil).Body.String(), "Index")
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), "/notes/.hidden/", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), "/nothing/", nil).Code)
+
+	asteroid.Prefix = "/wiki"
+	body = serveRoute(t, asteroid.Handler(), "/wiki/notes/", nil).Body.String()
+	assert.Contains(t, body, `<a href="/wiki/notes/sub/">sub/</a></td>`)
+	assert.Contains(t, body, `<a href="/wiki/notes/a.md">a.md</a></td>`)
+}
+
+func TestDirIndexRoot(t *testing.T) {
+	files := fstest.MapFS{"notes/a.md": {Data: []byte("# A")}, "b.md": {Data: []byte("# B")}}
+	asteroid := NewAsteroid(files, "Index")
+	_, e := asteroid.NewHandler()
+	assert.ErrorIs(t, e, ErrNoIndex)
+
+	asteroid.DirIndex = true
+	handler, e := asteroid.NewHandler()
+	require.NoError(t, e, "the root is listed")
+	response := serveRoute(t, handler, "/", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `<a href="/notes/">notes/</a></td>`)
+	assert.Contains(t, response.Body.String(), `<a href="/b.md">b.md</a></td>`)
+}
@@ -0,0 +1,146 @@
+package gnAsteroid
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gotuna/gotuna"
+)
+
+// Error pages: errors are rendered by renderError, with the view named after
+// their status (see errorViews), and the layout of the theme.
+// Asteroids can replace the 404 page with their own NotFoundPage.
+
+// NotFoundPage is the page of an asteroid served, with status 404, for missing pages.
+const NotFoundPage = "404.md"
+
+// errorViews are the views of the error pages. Other statuses use 500.html.
+var errorViews = map[int]string{
+	http.StatusForbidden:           "403.html",
+	http.StatusNotFound:            "404.html",
+	http.StatusInternalServerError: "500.html",
+	http.StatusBadGateway:          "502.html",
+	http.StatusGatewayTimeout:      "504.html",
+}
+
+// errorStatus returns the status of the page of err, a query error:
+// 504 if the gnoland node didn't answer in time, 502 if it is unreachable,
+// 404 if the realm, package or file doesn't exist, 500 otherwise.
+// (Realms not declaring Render are not an error, see handleRealmRender.)
+func errorStatus(err error) int {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errChainUnreachable):
+		return http.StatusBadGateway
+	case isNotFound(err):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// isNotFound tells whether err is the answer of a gnoland node to a query for
+// a realm, a package or a file which doesn't exist.
+func isNotFound(err error) bool {
+	if errors.As(err, &vm.InvalidPkgPathError{}) {
+		return true
+	}
+	msg := err.Error()
+	// e.g. `package "gno.land/r/demo/nope" is not available`, "package not found: gno.land/r/demo/nope"
+	return strings.Contains(msg, "is not available") || strings.Contains(msg, "package not found")
+}
+
+// isNoRenderDecl tells whether err is the answer of a gnoland node to a query
+// for the render of a realm not declaring Render.
+func isNoRenderDecl(err error) bool {
+	// XXX hack
+	return errors.As(err, &vm.NoRenderDeclError{}) || strings.Contains(err.Error(), "Render not declared") ||
+		strings.Contains(err.Error(), vm.NoRenderDeclError{}.Error())
+}
+
+// renderError renders the error page of status for the request r.
+// err, if not nil, is logged for 5xx pages, but not shown: it may tell
+// local paths or the address of the node.
+func renderError(logger *slog.Logger, app gotuna.App, cfg *Config, w http.ResponseWriter, r *http.Request, status int, err error) {
+	view, ok := errorViews[status]
+	if !ok {
+		view = errorViews[http.StatusInternalServerError]
+	}
+	if err != nil && status >= 500 {
+		logger.Error("error page", "status", status, "path", r.URL.Path, "error", err)
+	}
+	w.WriteHeader(status)
+	app.NewTemplatingEngine().
+		Set("title", http.StatusText(status)).
+		Set("path", r.URL.Path).
+		Set("Config", cfg).
+		Render(w, r, view, "funcs.html")
+}
+
+// notFound serves the NotFoundPage of the asteroid, if any, or the 404 page.
+func (a *Asteroid) notFound(w http.ResponseWriter, r *http.Request, app gotuna.App, cfg *Config) {
+	content, e := fs.ReadFile(a.FS, NotFoundPage)
+	if e != nil {
+		renderError(a.logger(), app, cfg, w, r, http.StatusNotFound, nil)
+		return
+	}
+	pureMarkdown, fm := frontMatterOf(a.logger(), NotFoundPage, string(content))
+	html, e := renderMarkdown(pureMarkdown, asteroidPolicy)
+	if e != nil {
+		renderError(a.logger(), app, cfg, w, r, http.StatusNotFound, nil)
+		return
+	}
+	pageName := fm.String("title")
+	if pageName == "" {
+		pageName = http.StatusText(http.StatusNotFound)
+	}
+	w.WriteHeader(http.StatusNotFound)
+	app.NewTemplatingEngine().
+		Set("AsteroidName", a.Name).
+		Set("AtHome", "0").
+		Set("PageName", pageName).
+		Set("FrontMatter", fm).
+		Set("Content", pureMarkdown).
+		Set("HTML", prefixLinks(html, basePath(r))).
+		Set("Config", cfg).
+		Render(w, r, "asteroid_markdown.html", "funcs.html")
+}
+
+// unservablePage is the error page of asteroids which can't be served at all,
+// e.g. their views don't parse: not rendered, nor themed.
+const unservablePage = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta name="viewport" content="width=device-width,initial-scale=1" />
+    <title>500 - Internal Server Error</title>
+  </head>
+  <body>
+    <h1>500 - Internal Server Error</h1>
+    <p>Something went wrong while serving this page.</p>
+  </body>
+</html>
+`
+
+// serveUnservable serves the unservablePage, whatever the request.
+func serveUnservable(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	io.WriteString(w, unservablePage)
+}
+
+// writeError renders the error page of err, a query error, see errorStatus.
+func writeError(logger *slog.Logger, app gotuna.App, cfg *Config, w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	if status == http.StatusNotFound {
+		logger.Debug("handler", "error", err)
+	}
+	renderError(logger, app, cfg, w, r, status, err)
+}
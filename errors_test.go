@@ -0,0 +1,117 @@
+package gnAsteroid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorStatus(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("unable to query path: %w: %w", errChainUnreachable, context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{fmt.Errorf("unable to query path: %w: %w", errChainUnreachable, errors.New("connection refused")), http.StatusBadGateway},
+		{vm.InvalidPkgPathError{}, http.StatusNotFound},
+		{abci.StringError(`package "gno.land/r/demo/nothing" is not available`), http.StatusNotFound},
+		{fmt.Errorf("request failed: %w", abci.StringError("package not found: gno.land/r/demo/nothing")), http.StatusNotFound},
+		{errors.New("invalid render path"), http.StatusInternalServerError},
+	} {
+		assert.Equal(t, tc.status, errorStatus(tc.err), tc.err.Error())
+	}
+	assert.True(t, isNoRenderDecl(vm.NoRenderDeclError{}))
+	assert.True(t, isNoRenderDecl(abci.StringError("render function not declared")))
+	assert.False(t, isNoRenderDecl(abci.StringError("invalid render path")))
+}
+
+func TestChainErrorPages(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	for _, tc := range []struct {
+		remote, route string
+		status        int
+		substring     string
+	}{
+		{mockChain(t), "/r/demo/nothing", http.StatusNotFound, "404 - Not Found"},
+		{mockChain(t), "/r/demo/norender", http.StatusNotImplemented, "no Render() function"},
+		{mockChain(t), "/r/demo/users:nobody", http.StatusInternalServerError, "500 - Internal Server Error"},
+		{down.URL, "/r/demo/users", http.StatusBadGateway, "502 - Bad Gateway"},
+		{slow.URL, "/r/demo/users", http.StatusGatewayTimeout, "504 - Gateway Timeout"},
+	} {
+		cfg := configWith(tc.remote)
+		cfg.QueryTimeout = 50 * time.Millisecond
+		app := MakeGnowebAppWithOptions(slog.Default(), &cfg, Options{})
+		response := serveRoute(t, app.Router, tc.route, nil)
+		assert.Equal(t, tc.status, response.Code, tc.route)
+		assert.Contains(t, response.Body.String(), tc.substring, tc.route)
+		assert.Contains(t, response.Body.String(), "/static/css/", "themed")
+		assert.NotContains(t, response.Body.String(), strings.TrimPrefix(tc.remote, "http://"), "details are logged, not shown")
+	}
+}
+
+func TestNotFoundPage(t *testing.T) {
+	files := fstest.MapFS{
+		"index.md":  {Data: []byte("# Home")},
+		"script.sh": {Data: []byte("rm -rf /")},
+	}
+	get := func(route string) *httptest.ResponseRecorder {
+		return serveRoute(t, NewAsteroid(files, "Vesta").Handler(), route, nil)
+	}
+	for _, route := range []string{"/nothing.md", "/script.sh", "/.hidden", "/tags/nothing/"} {
+		response := get(route)
+		assert.Equal(t, http.StatusNotFound, response.Code, route)
+		assert.Contains(t, response.Body.String(), "404 - Not Found", route)
+	}
+	response := get("/..\\..\\index.md")
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.Contains(t, response.Body.String(), "403 - Forbidden")
+
+	files[NotFoundPage] = &fstest.MapFile{Data: []byte("---\ntitle: Lost in space\n---\nTry the [home page](/).")}
+	for _, route := range []string{"/nothing.md", "/script.sh", "/tags/nothing/"} {
+		response := get(route)
+		assert.Equal(t, http.StatusNotFound, response.Code, route)
+		assert.Contains(t, response.Body.String(), "Lost in space", route)
+		assert.Contains(t, response.Body.String(), `<a href="/"`, route)
+	}
+}
+
+// unreadableViewsFS is a theme whose views can't be read.
+type unreadableViewsFS struct{ files fstest.MapFS }
+
+func (f unreadableViewsFS) Open(name string) (fs.File, error) {
+	if strings.HasPrefix(name, ThemeViewsDir+"/") {
+		return nil, &fs.PathError{Op: "open", Path: "/home/bob/theme/" + name, Err: fs.ErrPermission}
+	}
+	return f.files.Open(name)
+}
+
+// an asteroid which can't be served at all, e.g. its views can't be read
+func TestUnservable(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{"index.md": {Data: []byte("# Home")}}, "Vesta")
+	asteroid.ThemeFS = unreadableViewsFS{fstest.MapFS{"views/404.html": {Data: []byte("lost")}}}
+	handler, e := asteroid.NewHandler()
+	assert.Error(t, e)
+	assert.Nil(t, handler)
+	response := serveRoute(t, asteroid.Handler(), "/", nil)
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Contains(t, response.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, response.Body.String(), "500 - Internal Server Error")
+	assert.NotContains(t, response.Body.String(), "/home/bob", "logged, not shown")
+}
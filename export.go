@@ -0,0 +1,323 @@
+package gnAsteroid
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gnAsteroid/gnAsteroid/static"
+)
+
+// Export writes the asteroid as a self-contained directory that any static
+// host can serve: markdown pages rendered through the same handler and views
+// as when serving, images, and theme and static assets.
+//
+// Links to markdown pages are rewritten to the exported .html pages,
+// links to realms and packages (/r/..., /p/...) are rewritten to chainURL
+// (e.g. "https://gno.land"), unless chainURL is empty.
+//
+// Taxonomy pages (e.g. /tags/ and /tags/gno/) are exported as directories,
+// so are the generated indexes of directories, if a.DirIndex.
+//
+// Feeds (/feed.xml, /atom.xml, /feed.json) have absolute links, they are
+// exported only if siteURL, where the website will be published (e.g. "https://example.com"), is given.
+// The asteroid is exported as served under the path of siteURL, if any (e.g. "https://example.com/wiki"),
+// and its internal links are relative, so that the website can be published under any path.
+//
+// Unpublished files (see IgnoreFile) are not exported.
+//
+// Internal links pointing nowhere are returned as broken links.
+func (a *Asteroid) Export(outDir, chainURL, siteURL string) (broken []BrokenLink, err error) {
+	site, e := url.Parse(siteURL)
+	if e != nil {
+		return nil, fmt.Errorf("site url %q: %w", siteURL, e)
+	}
+	prefix := strings.TrimSuffix(site.Path, "/")
+	b := *a.published()
+	b.Prefix = prefix
+	a = &b
+	handler, e := a.NewHandler()
+	if e != nil {
+		return nil, e
+	}
+	themeFs := a.ThemeFS
+	if themeFs == nil {
+		themeFs = os.DirFS(DefaultTheme)
+	}
+
+	// 1. list what can be served: url path -> exported file path
+	exported := map[string]string{}
+	dirs := map[string]bool{} // url paths of directories having an index page
+	e = fs.WalkDir(a.FS, ".", func(p string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if p != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != "." && a.DirIndex && !hasIndex(a.FS, p) {
+				dirs["/"+p+"/"] = true
+			}
+			return nil
+		}
+		if strings.HasSuffix(p, ".md") {
+			exported["/"+p] = strings.TrimSuffix(p, ".md") + ".html"
+		} else {
+			exported["/"+p] = p
+		}
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	// directories are served by their index.md, or README.md
+	for urlPath := range exported {
+		dir, file := path.Split(urlPath)
+		if dir == "/" || (file != "index.md" && file != "README.md") {
+			continue
+		}
+		if file == "README.md" {
+			if _, hasIndex := exported[dir+"index.md"]; hasIndex {
+				continue
+			}
+		}
+		dirs[dir] = true
+	}
+	pages := loadPages(a.logger(), a.FS)
+	for _, name := range a.taxonomies() {
+		dirs["/"+name+"/"] = true
+		for _, term := range newTaxonomy(pages, name).Terms {
+			dirs[term.URL(name)] = true
+		}
+	}
+	for dir := range dirs {
+		exported[dir] = strings.TrimPrefix(dir, "/") + "index.html"
+	}
+	exported["/"] = "index.html"
+	for _, assets := range []fs.FS{static.EmbeddedStatic, themeFs} {
+		e := fs.WalkDir(assets, ".", func(p string, d fs.DirEntry, e error) error {
+			if e != nil {
+				return e
+			}
+			if !d.IsDir() && !strings.HasSuffix(p, ".go") {
+				exported["/static/"+p] = "static/" + p
+			}
+			return nil
+		})
+		if e != nil {
+			return nil, e
+		}
+	}
+	exported["/favicon.ico"] = "favicon.ico"
+	unexported := map[string]bool{} // url paths which can be linked to, but are not exported
+	for feed := range feedFiles {
+		if siteURL != "" {
+			exported["/"+feed] = feed
+		} else {
+			unexported["/"+feed] = true
+		}
+	}
+
+	// 2. fetch everything through the handler, files which can't be served are not exported
+	urlPaths := make([]string, 0, len(exported))
+	for urlPath := range exported {
+		urlPaths = append(urlPaths, urlPath)
+	}
+	sort.Strings(urlPaths)          // e.g. "/" is fetched before "/index.md"
+	contents := map[string][]byte{} // exported file path -> content
+	for _, urlPath := range urlPaths {
+		filename := exported[urlPath]
+		if _, done := contents[filename]; done {
+			continue
+		}
+		request := httptest.NewRequest(http.MethodGet, (&url.URL{Scheme: site.Scheme, Host: site.Host, Path: prefix + urlPath}).String(), nil)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != http.StatusOK {
+			if urlPath == "/" {
+				return nil, fmt.Errorf("exporting %s: %s", request.URL, http.StatusText(response.Code))
+			}
+			delete(exported, urlPath)
+			continue
+		}
+		contents[filename] = response.Body.Bytes()
+	}
+	exists := func(urlPath string) bool {
+		_, ok := exported[urlPath]
+		return ok || dirs[urlPath+"/"] || unexported[urlPath]
+	}
+
+	// 3. rewrite links of pages, and write
+	written := map[string]bool{}
+	for _, urlPath := range urlPaths {
+		filename, ok := exported[urlPath]
+		if !ok || written[filename] {
+			continue
+		}
+		content := contents[filename]
+		written[filename] = true
+		base := urlPath
+		if !strings.HasSuffix(urlPath, "/") {
+			base = strings.TrimSuffix(path.Dir(urlPath), "/") + "/"
+		}
+		if strings.HasSuffix(filename, ".css") {
+			content = relativeURLs(content, base, prefix, exists)
+		} else if strings.HasSuffix(filename, ".html") && !strings.HasPrefix(urlPath, "/static/") {
+			// an index page is also served without trailing slash (e.g. linked as "[svg](svg)"),
+			// its relative links then resolve from the parent directory
+			parent := ""
+			if urlPath != "/" && strings.HasSuffix(urlPath, "/") {
+				parent = strings.TrimSuffix(path.Dir(strings.TrimSuffix(urlPath, "/")), "/") + "/"
+			}
+			var links []string
+			content, links = rewriteLinks(content, base, parent, prefix, chainURL, exists, dirs)
+			for _, link := range links {
+				broken = append(broken, BrokenLink{Page: filename, Link: link})
+			}
+		}
+		out := filepath.Join(outDir, filepath.FromSlash(filename))
+		if e := os.MkdirAll(filepath.Dir(out), 0o755); e != nil {
+			return broken, e
+		}
+		if e := os.WriteFile(out, content, 0o644); e != nil {
+			return broken, e
+		}
+	}
+	return broken, nil
+}
+
+// BrokenLink is an internal link pointing nowhere, found by Export.
+type BrokenLink struct {
+	Page string // the exported page, e.g. "subdir/index.html"
+	Link string // the link as written in the page
+}
+
+func (b BrokenLink) String() string { return b.Page + ": " + b.Link }
+
+var reLink = regexp.MustCompile(`(href|src)="([^"]*)"`)
+var reScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// rewriteLinks rewrites links of an html page served at base (e.g. "/subdir/"),
+// returning links pointing nowhere according to exists.
+// Root-relative links (e.g. "/wiki/static/css/common.css", prefix being "/wiki") are rewritten relative to base.
+// Relative links pointing nowhere from base are resolved from parent, if not empty,
+// as the page is also served there, and rewritten relative to base.
+// dirs are the url paths of directories, with a trailing slash.
+func rewriteLinks(page []byte, base, parent, prefix, chainURL string, exists func(urlPath string) bool, dirs map[string]bool) ([]byte, []string) {
+	var broken []string
+	page = reLink.ReplaceAllFunc(page, func(attr []byte) []byte {
+		m := reLink.FindSubmatch(attr)
+		name, link := string(m[1]), string(m[2])
+		if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "//") || reScheme.MatchString(link) {
+			return attr // external, or an anchor
+		}
+		target, suffix := link, ""
+		if i := strings.IndexAny(target, "?#"); i != -1 {
+			target, suffix = target[:i], target[i:]
+		}
+		relative := !strings.HasPrefix(target, "/")
+		if relative {
+			target = resolveLink(base, target)
+		} else {
+			target = unprefixed(prefix, target)
+		}
+		if strings.HasPrefix(target, "/r/") || strings.HasPrefix(target, "/p/") {
+			if chainURL == "" {
+				return attr
+			}
+			return []byte(name + `="` + strings.TrimSuffix(chainURL, "/") + target + suffix + `"`)
+		}
+		link = link[:len(link)-len(suffix)]
+		original := link
+		if !relative {
+			link = relativeLink(base, target)
+		}
+		if relative && parent != "" && !exists(unescapePath(target)) {
+			if from := resolveLink(parent, link); exists(unescapePath(from)) {
+				target, link = from, relativeLink(base, from)
+			}
+		}
+		target = unescapePath(target)
+		if !exists(target) {
+			broken = append(broken, link+suffix)
+			return attr
+		}
+		if dirs[target+"/"] {
+			// static hosts serve directories with a trailing slash only
+			return []byte(name + `="` + link + "/" + suffix + `"`)
+		} else if link, has := strings.CutSuffix(link, ".md"); has {
+			return []byte(name + `="` + link + ".html" + suffix + `"`)
+		}
+		if link != original {
+			return []byte(name + `="` + link + suffix + `"`)
+		}
+		return attr
+	})
+	return page, broken
+}
+
+var reCSSURL = regexp.MustCompile(`url\(("?)(/[^/")][^")]*)("?)\)`)
+
+// relativeURLs rewrites the root-relative urls (e.g. url("/static/font/a.woff")) of a
+// stylesheet served at base (e.g. "/static/css/") relative to base, if they exist.
+func relativeURLs(css []byte, base, prefix string, exists func(urlPath string) bool) []byte {
+	return reCSSURL.ReplaceAllFunc(css, func(u []byte) []byte {
+		m := reCSSURL.FindSubmatch(u)
+		target := unprefixed(prefix, string(m[2]))
+		if !exists(unescapePath(target)) {
+			return u
+		}
+		return []byte("url(" + string(m[1]) + relativeLink(base, target) + string(m[3]) + ")")
+	})
+}
+
+// unprefixed returns the url path p (e.g. "/wiki/about.md") without prefix (e.g. "/wiki").
+func unprefixed(prefix, p string) string {
+	if p == prefix {
+		return "/"
+	} else if rest, ok := strings.CutPrefix(p, prefix+"/"); ok && prefix != "" {
+		return "/" + rest
+	}
+	return p
+}
+
+// resolveLink returns the url path of the relative link in a page of the directory base.
+func resolveLink(base, link string) string {
+	target := path.Join(base, link)
+	if strings.HasSuffix(link, "/") {
+		target += "/"
+	}
+	return target
+}
+
+// relativeLink returns the relative link from a page of the directory base (e.g. "/svg/")
+// to the url path target, e.g. "../about.md" to "/about.md", "./" to base itself.
+func relativeLink(base, target string) string {
+	dir, up := strings.TrimSuffix(base, "/"), ""
+	for dir != "" && !strings.HasPrefix(target, dir+"/") {
+		dir, up = dir[:strings.LastIndex(dir, "/")], up+"../"
+	}
+	if link := up + strings.TrimPrefix(target, dir+"/"); link != "" {
+		return link
+	}
+	return "./"
+}
+
+func unescapePath(p string) string {
+	if unescaped, e := url.PathUnescape(p); e == nil {
+		return unescaped
+	}
+	return p
+}
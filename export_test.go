@@ -0,0 +1,124 @@
+package gnAsteroid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{
+		"index.md":          {Data: []byte("[about](about.md) [blog](blog) [gnoface](/r/demo/art/gnoface:1) [nowhere](nowhere.md)")},
+		"about.md":          {Data: []byte("![me](img/me.png) [home](/) [post](blog/first.md#intro)")},
+		"blog/README.md":    {Data: []byte("[first](first.md) [up](../about.md)")},
+		"blog/first.md":     {Data: []byte("---\ntags: [Gno Land]\n---\n# intro")},
+		"img/me.png":        {Data: []byte("png")},
+		"img/notes.unknown": {Data: []byte("not served")},
+		"blog/draft.md":     {Data: []byte("---\ndraft: true\n---\nnot published")},
+		".TITLE":            {Data: []byte("hidden")},
+	}, "Export")
+	out := t.TempDir()
+	broken, e := asteroid.Export(out, "https://gno.land", "")
+	require.NoError(t, e)
+	assert.Equal(t, []BrokenLink{{Page: "index.html", Link: "nowhere.md"}}, broken)
+
+	read := func(name string) string {
+		content, e := os.ReadFile(filepath.Join(out, name))
+		require.NoError(t, e, name)
+		return string(content)
+	}
+	index := read("index.html")
+	assert.Contains(t, index, `href="about.html"`)
+	assert.Contains(t, index, `href="blog/"`)
+	assert.Contains(t, index, `href="https://gno.land/r/demo/art/gnoface:1"`)
+	about := read("about.html")
+	assert.Contains(t, about, `src="img/me.png"`)
+	assert.Contains(t, about, `href="blog/first.html#intro"`)
+	blog := read("blog/index.html")
+	assert.Contains(t, blog, `href="first.html"`)
+	assert.Contains(t, blog, `href="../about.html"`)
+	assert.Contains(t, read("blog/README.html"), `href="first.html"`)
+	assert.Equal(t, "png", read("img/me.png"))
+	assert.Contains(t, index, `href="static/css/common.css"`)
+	assert.Contains(t, read("tags/index.html"), `href="gno-land/"`)
+	assert.Contains(t, read("tags/gno-land/index.html"), `href="../../blog/first.html"`)
+	assert.Contains(t, read("tags/gno-land/index.html"), `href="../../static/css/common.css"`)
+	read("static/css/common.css")
+	read("static/js/renderer.js")
+
+	for _, missing := range []string{".TITLE", "img/notes.unknown", "static/static.go", "feed.xml", "blog/draft.html"} {
+		_, e := os.Stat(filepath.Join(out, missing))
+		assert.True(t, os.IsNotExist(e), missing)
+	}
+
+	// feeds need to know where the website is published
+	out = t.TempDir()
+	_, e = asteroid.Export(out, "https://gno.land", "https://example.com")
+	require.NoError(t, e)
+	assert.Contains(t, read("feed.xml"), "<link>https://example.com/</link>")
+	read("atom.xml")
+	read("feed.json")
+
+	// published under a path
+	out = t.TempDir()
+	broken, e = asteroid.Export(out, "https://gno.land", "https://example.com/wiki/")
+	require.NoError(t, e)
+	assert.Equal(t, []BrokenLink{{Page: "index.html", Link: "nowhere.md"}}, broken)
+	index = read("index.html")
+	assert.Contains(t, index, `href="about.html"`)
+	assert.Contains(t, index, `href="static/css/common.css"`)
+	assert.Contains(t, index, `href="https://gno.land/r/demo/art/gnoface:1"`)
+	assert.Contains(t, read("about.html"), `href="./"`, "home")
+	assert.Contains(t, read("blog/index.html"), `src="../static/js/renderer.js"`)
+	assert.Contains(t, read("feed.xml"), "<link>https://example.com/wiki/</link>")
+	assert.Contains(t, read("static/css/app.css"), `url("../font/roboto/RobotoMono-Regular.woff")`)
+
+	// directories without index are exported with a generated one, if asked
+	_, e = os.Stat(filepath.Join(out, "img", "index.html"))
+	assert.True(t, os.IsNotExist(e))
+	asteroid.DirIndex = true
+	out = t.TempDir()
+	_, e = asteroid.Export(out, "https://gno.land", "")
+	require.NoError(t, e)
+	assert.Contains(t, read("img/index.html"), `id="dir_index"`)
+	assert.NotContains(t, read("blog/index.html"), `id="dir_index"`)
+}
+
+// an index page linked without trailing slash, e.g. [gallery](gallery), is served at /gallery:
+// its relative links resolve from the root
+func TestExportIndexLinkedWithoutSlash(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{
+		"index.md":          {Data: []byte("[gallery](gallery)")},
+		"about.md":          {Data: []byte("# About")},
+		"gallery/index.md":  {Data: []byte("![a](gallery/img/a.png) [about](about.md) [b](img/b.png) [nowhere](gallery/nowhere.md)")},
+		"gallery/img/a.png": {Data: []byte("png")},
+		"gallery/img/b.png": {Data: []byte("png")},
+	}, "Export")
+	out := t.TempDir()
+	broken, e := asteroid.Export(out, "", "")
+	require.NoError(t, e)
+	assert.Equal(t, []BrokenLink{{Page: "gallery/index.html", Link: "gallery/nowhere.md"}}, broken)
+	content, e := os.ReadFile(filepath.Join(out, "index.html"))
+	require.NoError(t, e)
+	assert.Contains(t, string(content), `href="gallery/"`)
+	content, e = os.ReadFile(filepath.Join(out, "gallery", "index.html"))
+	require.NoError(t, e)
+	assert.Contains(t, string(content), `src="img/a.png"`)
+	assert.Contains(t, string(content), `href="../about.html"`)
+	assert.Contains(t, string(content), `href="img/b.png"`)
+}
+
+func TestRelativeLink(t *testing.T) {
+	for _, tc := range []struct{ base, target, link string }{
+		{"/svg/", "/svg/img/a.svg", "img/a.svg"},
+		{"/svg/", "/about.md", "../about.md"},
+		{"/a/b/", "/a/c.md", "../c.md"},
+		{"/", "/a/c.md", "a/c.md"},
+	} {
+		assert.Equal(t, tc.link, relativeLink(tc.base, tc.target), tc)
+	}
+}
@@ -0,0 +1,363 @@
+package gnAsteroid
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gotuna/gotuna"
+)
+
+// Feeds of the pages having a front matter date, newest first:
+// /feed.xml (RSS 2.0), /atom.xml (Atom) and /feed.json (JSON Feed 1.1).
+// Each directory has its own, e.g. /blog/feed.xml for the pages under blog/.
+// The root feeds have every dated page, or only those under Asteroid.FeedDirs.
+// Feeds of the asteroid itself (e.g. a feed.json of its own) are served instead, as its other files.
+
+const feedMaxItems = 50
+
+// feedFiles are the names of the feeds, and their formats.
+var feedFiles = map[string]string{"feed.xml": "rss", "atom.xml": "atom", "feed.json": "json"}
+
+type feedItem struct {
+	Dir         string // directory of the page, e.g. "blog/", "" at the root
+	URL         string // url path, e.g. "/blog/first.md"
+	Title       string
+	Description string
+	Author      string
+	Tags        []string
+	Date        time.Time
+	HTML        string // with relative links, see feed.html
+}
+
+// newFeedItems returns the dated pages, newest first.
+func newFeedItems(pages []page) []feedItem {
+	var items []feedItem
+	for _, p := range pages {
+		date, ok := p.date()
+		if !ok {
+			continue
+		}
+		html, e := renderMarkdown(p.Markdown, asteroidPolicy)
+		if e != nil {
+			continue
+		}
+		dir, _ := path.Split(p.File)
+		items = append(items, feedItem{
+			Dir:         dir,
+			URL:         p.URL,
+			Title:       p.title(),
+			Description: p.Meta.String("description"),
+			Author:      p.Meta.String("author"),
+			Tags:        p.Meta.Strings("tags"),
+			Date:        date,
+			HTML:        string(html),
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].Date.Equal(items[j].Date) {
+			return items[i].Date.After(items[j].Date)
+		}
+		return items[i].URL < items[j].URL
+	})
+	return items
+}
+
+// feedItemsIn returns the items under one of dirs (e.g. "blog", "news/2024"), or all if dirs is empty.
+func feedItemsIn(items []feedItem, dirs ...string) []feedItem {
+	if len(dirs) == 0 {
+		return items
+	}
+	var in []feedItem
+	for _, item := range items {
+		for _, dir := range dirs {
+			if dir = strings.Trim(dir, "/"); dir == "" || strings.HasPrefix(item.Dir, dir+"/") {
+				in = append(in, item)
+				break
+			}
+		}
+	}
+	return in
+}
+
+// handleFeed serves a feed in format ("rss", "atom" or "json"),
+// of the directory in the route variable "dir", or of the root.
+func (a *Asteroid) handleFeed(items []feedItem, format string, app gotuna.App, cfg *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dir := mux.Vars(r)["dir"]
+		title := a.Name
+		var list []feedItem
+		if dir != "" {
+			if stat, e := fs.Stat(a.FS, dir); e != nil || !stat.IsDir() || strings.HasPrefix(path.Base(dir), ".") {
+				a.notFound(w, r, app, cfg)
+				return
+			} else if _, e := fs.Stat(a.FS, path.Join(dir, path.Base(r.URL.Path))); e == nil {
+				app.Router.NotFoundHandler.ServeHTTP(w, r) // see HandleNotFoundAsFile
+				return
+			}
+			list = feedItemsIn(items, dir)
+			title += " - " + dir
+		} else {
+			list = feedItemsIn(items, a.FeedDirs...)
+		}
+		if len(list) > feedMaxItems {
+			list = list[:feedMaxItems]
+		}
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		f := feed{
+			base:  &url.URL{Scheme: scheme, Host: r.Host, Path: basePath(r)},
+			dir:   "/" + strings.TrimPrefix(dir+"/", "/"),
+			title: title,
+			self:  r.URL.Path,
+			items: list,
+		}
+		var out []byte
+		var e error
+		switch format {
+		case "rss":
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+			out, e = f.rss()
+		case "atom":
+			w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+			out, e = f.atom()
+		default:
+			w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+			out, e = f.json()
+		}
+		if e != nil {
+			renderError(a.logger(), app, cfg, w, r, http.StatusInternalServerError, e)
+			return
+		}
+		w.Write(out)
+	})
+}
+
+type feed struct {
+	base  *url.URL // e.g. "https://example.com/wiki", with the prefix of the asteroid (see basePath)
+	dir   string   // url path of the directory, e.g. "/blog/"
+	title string
+	self  string // url path of the feed
+	items []feedItem
+}
+
+// link returns the absolute url of the url path p of the asteroid, e.g. "/blog/first.md".
+func (f feed) link(p string) string {
+	u := *f.base
+	u.Path += p
+	return u.String()
+}
+
+// html returns the HTML of item, its links made absolute, for feed readers to resolve them.
+func (f feed) html(item feedItem) string {
+	page, e := url.Parse(f.link(item.URL))
+	if e != nil {
+		return item.HTML
+	}
+	return reLink.ReplaceAllStringFunc(item.HTML, func(attr string) string {
+		m := reLink.FindStringSubmatch(attr)
+		link, e := url.Parse(html.UnescapeString(m[2]))
+		if e != nil {
+			return attr
+		}
+		if strings.HasPrefix(m[2], "/") && !strings.HasPrefix(m[2], "//") {
+			link.Path = f.base.Path + link.Path // root-relative links are under the prefix, see prefixLinks
+		}
+		return m[1] + `="` + html.EscapeString(page.ResolveReference(link).String()) + `"`
+	})
+}
+
+func (f feed) updated() time.Time {
+	if len(f.items) == 0 {
+		return time.Unix(0, 0).UTC()
+	}
+	return f.items[0].Date
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+func (f feed) rss() ([]byte, error) {
+	channel := rssChannel{
+		Title:         f.title,
+		Link:          f.link(f.dir),
+		Description:   f.title,
+		LastBuildDate: f.updated().Format(time.RFC1123Z),
+	}
+	for _, item := range f.items {
+		description := item.Description
+		if description == "" {
+			description = f.html(item)
+		}
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        f.link(item.URL),
+			GUID:        f.link(item.URL),
+			PubDate:     item.Date.Format(time.RFC1123Z),
+			Description: description,
+			Creator:     item.Author,
+			Categories:  item.Tags,
+		})
+	}
+	return marshalXML(rssFeed{Version: "2.0", DC: "http://purl.org/dc/elements/1.1/", Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+}
+
+func (f feed) atom() ([]byte, error) {
+	out := atomFeed{
+		Title:   f.title,
+		ID:      f.link(f.dir),
+		Updated: f.updated().Format(time.RFC3339),
+		Links:   []atomLink{{Href: f.link(f.dir)}, {Href: f.link(f.self), Rel: "self"}},
+		Author:  atomPerson{Name: f.title},
+	}
+	for _, item := range f.items {
+		entry := atomEntry{
+			Title:     item.Title,
+			ID:        f.link(item.URL),
+			Updated:   item.Date.Format(time.RFC3339),
+			Published: item.Date.Format(time.RFC3339),
+			Link:      atomLink{Href: f.link(item.URL)},
+			Content:   atomText{Type: "html", Body: f.html(item)},
+		}
+		if item.Author != "" {
+			entry.Author = &atomPerson{Name: item.Author}
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if item.Description != "" {
+			entry.Summary = &atomText{Type: "text", Body: item.Description}
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	return marshalXML(out)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	out, e := xml.MarshalIndent(v, "", "  ")
+	if e != nil {
+		return nil, e
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished string           `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+func (f feed) json() ([]byte, error) {
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.title,
+		HomePageURL: f.link(f.dir),
+		FeedURL:     f.link(f.self),
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range f.items {
+		jsonItem := jsonFeedItem{
+			ID:            f.link(item.URL),
+			URL:           f.link(item.URL),
+			Title:         item.Title,
+			ContentHTML:   f.html(item),
+			Summary:       item.Description,
+			DatePublished: item.Date.Format(time.RFC3339),
+			Tags:          item.Tags,
+		}
+		if item.Author != "" {
+			jsonItem.Authors = []jsonFeedAuthor{{Name: item.Author}}
+		}
+		out.Items = append(out.Items, jsonItem)
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
@@ -0,0 +1,139 @@
+package gnAsteroid
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var blogFS = fstest.MapFS{
+	"index.md":          {Data: []byte("# Home")},
+	"about.md":          {Data: []byte("---\ntitle: About\n---\nNo date, not in feeds.")},
+	"blog/index.md":     {Data: []byte("# Blog")},
+	"blog/first.md":     {Data: []byte("---\ntitle: First post\ndate: 2024-01-02\nauthor: Bob\ntags: [gno, asteroids]\n---\nHello **world**.")},
+	"blog/second.md":    {Data: []byte("---\ntitle: Second post\ndate: 2024-03-04T10:00:00Z\ndescription: The sequel.\n---\nAgain.")},
+	"news/launch.md":    {Data: []byte("---\ntitle: Launch\ndate: 2024-02-01 12:30\n---\nWe launched.")},
+	"news/bad-date.md":  {Data: []byte("---\ntitle: Bad date\ndate: someday\n---\nNot in feeds.")},
+	".drafts/hidden.md": {Data: []byte("---\ntitle: Hidden\ndate: 2025-01-01\n---\nNot in feeds.")},
+}
+
+// getFeed gets route from asteroid, served at https://example.com.
+func getFeed(t *testing.T, asteroid *Asteroid, route string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveRoute(t, asteroid.Handler(), "https://example.com"+route, nil)
+}
+
+func TestFeedRSS(t *testing.T) {
+	response := getFeed(t, NewAsteroid(blogFS, "Bob"), "/feed.xml")
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", response.Header().Get("Content-Type"))
+	var rss rssFeed
+	require.NoError(t, xml.Unmarshal(response.Body.Bytes(), &rss))
+	require.Len(t, rss.Channel.Items, 3)
+	assert.Equal(t, "Second post", rss.Channel.Items[0].Title)
+	assert.Equal(t, "The sequel.", rss.Channel.Items[0].Description)
+	assert.Equal(t, "https://example.com/blog/second.md", rss.Channel.Items[0].Link)
+	assert.Equal(t, "Mon, 04 Mar 2024 10:00:00 +0000", rss.Channel.Items[0].PubDate)
+	assert.Equal(t, "Launch", rss.Channel.Items[1].Title)
+	assert.Equal(t, "First post", rss.Channel.Items[2].Title)
+	assert.Equal(t, "<p>Hello <strong>world</strong>.</p>\n", rss.Channel.Items[2].Description)
+	assert.Equal(t, []string{"gno", "asteroids"}, rss.Channel.Items[2].Categories)
+	assert.Contains(t, response.Body.String(), "<dc:creator>Bob</dc:creator>")
+}
+
+func TestFeedAtom(t *testing.T) {
+	response := getFeed(t, NewAsteroid(blogFS, "Bob"), "/blog/atom.xml")
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", response.Header().Get("Content-Type"))
+	var atom atomFeed
+	require.NoError(t, xml.Unmarshal(response.Body.Bytes(), &atom))
+	assert.Equal(t, "Bob - blog", atom.Title)
+	assert.Equal(t, "2024-03-04T10:00:00Z", atom.Updated)
+	assert.Contains(t, atom.Links, atomLink{Href: "https://example.com/blog/atom.xml", Rel: "self"})
+	require.Len(t, atom.Entries, 2)
+	assert.Equal(t, "Second post", atom.Entries[0].Title)
+	assert.Equal(t, "First post", atom.Entries[1].Title)
+	assert.Equal(t, &atomPerson{Name: "Bob"}, atom.Entries[1].Author)
+}
+
+func TestFeedJSON(t *testing.T) {
+	asteroid := NewAsteroid(blogFS, "Bob")
+	asteroid.FeedDirs = []string{"news"}
+	response := getFeed(t, asteroid, "/feed.json")
+	require.Equal(t, http.StatusOK, response.Code)
+	var feed jsonFeed
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &feed))
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	assert.Equal(t, "https://example.com/feed.json", feed.FeedURL)
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "Launch", feed.Items[0].Title)
+	assert.Equal(t, "2024-02-01T12:30:00Z", feed.Items[0].DatePublished)
+
+	// FeedDirs only apply to the root feeds
+	response = getFeed(t, asteroid, "/blog/feed.json")
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &feed))
+	assert.Len(t, feed.Items, 2)
+
+	// empty feeds are still valid
+	response = getFeed(t, NewAsteroid(fstest.MapFS{"index.md": {Data: []byte("# Empty")}}, "Empty"), "/feed.json")
+	assert.JSONEq(t, `{
+		"version": "https://jsonfeed.org/version/1.1",
+		"title": "Empty",
+		"home_page_url": "https://example.com/",
+		"feed_url": "https://example.com/feed.json",
+		"items": []
+	}`, response.Body.String())
+}
+
+func TestFeedNotFound(t *testing.T) {
+	asteroid := NewAsteroid(blogFS, "Bob")
+	for _, route := range []string{"/nothing/feed.xml", "/blog/first.md/feed.xml", "/.drafts/atom.xml"} {
+		response := getFeed(t, asteroid, route)
+		assert.Equal(t, http.StatusNotFound, response.Code, route)
+		assert.Contains(t, response.Body.String(), "There is nothing here.", "themed")
+	}
+}
+
+func TestFeedLinks(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{
+		"index.md":        {Data: []byte("# Home")},
+		"blog/my post.md": {Data: []byte("---\ndate: 2024-01-02\n---\n![a](img/a.png) [home](/) [about](../about.md?x=1&y=2) [top](#top) [gno](https://gno.land/)")},
+	}, "Bob")
+	asteroid.Prefix = "/wiki"
+	response := getFeed(t, asteroid, "/wiki/feed.json")
+	require.Equal(t, http.StatusOK, response.Code)
+	var feed jsonFeed
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &feed))
+	assert.Equal(t, "https://example.com/wiki/", feed.HomePageURL)
+	assert.Equal(t, "https://example.com/wiki/feed.json", feed.FeedURL)
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "https://example.com/wiki/blog/my%20post.md", feed.Items[0].URL)
+	html := feed.Items[0].ContentHTML
+	assert.Contains(t, html, `src="https://example.com/wiki/blog/img/a.png"`)
+	assert.Contains(t, html, `href="https://example.com/wiki/"`)
+	assert.Contains(t, html, `href="https://example.com/wiki/about.md?x=1&amp;y=2"`)
+	assert.Contains(t, html, `href="https://example.com/wiki/blog/my%20post.md#top"`)
+	assert.Contains(t, html, `href="https://gno.land/"`)
+}
+
+// feeds of the asteroid itself are served instead
+func TestFeedShadowed(t *testing.T) {
+	files := fstest.MapFS{
+		"feed.json":      {Data: []byte(`{"title": "my own"}`)},
+		"blog/feed.json": {Data: []byte(`{"title": "my own blog"}`)},
+	}
+	for name, file := range blogFS {
+		files[name] = file
+	}
+	asteroid := NewAsteroid(files, "Bob")
+	assert.JSONEq(t, `{"title": "my own"}`, getFeed(t, asteroid, "/feed.json").Body.String())
+	assert.JSONEq(t, `{"title": "my own blog"}`, getFeed(t, asteroid, "/blog/feed.json").Body.String())
+	assert.Contains(t, getFeed(t, asteroid, "/feed.xml").Body.String(), "<rss")
+	assert.Contains(t, getFeed(t, asteroid, "/news/feed.json").Body.String(), "Launch")
+}
@@ -0,0 +1,255 @@
+package gnAsteroid
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	toml "github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter is the metadata heading a markdown page, with typed values:
+// strings, numbers, booleans, time.Time for dates, []any for lists and
+// FrontMatter for nested maps. Top-level keys are lower-cased.
+//
+// YAML front matter is enclosed by "---" lines, TOML front matter by "+++" lines:
+//
+//	---
+//	title: Document Title
+//	date: 2024-01-02
+//	tags: [tag1, tag2]
+//	draft: false
+//	author:
+//	  name: Your Name
+//	---
+//	And the rest is markdown.
+//
+//	+++
+//	title = "Document Title"
+//	date = 2024-01-02
+//	tags = ["tag1", "tag2"]
+//	+++
+//	And the rest is markdown.
+type FrontMatter map[string]any
+
+// ParseFrontMatter returns the front matter of content, and content without it.
+// The one line front matter of ExtractFrontMatter ("---title: Title---") is supported too.
+// If the front matter is invalid, an error is returned along with content without it, if it can be delimited.
+func ParseFrontMatter(content string) (pureMarkdown string, fm FrontMatter, err error) {
+	fm = FrontMatter{}
+	for _, format := range []struct {
+		delimiter string
+		unmarshal func(block string) (map[string]any, error)
+	}{
+		{"---", unmarshalYAML},
+		{"+++", unmarshalTOML},
+	} {
+		block, rest, found, e := cutFrontMatter(content, format.delimiter)
+		if e != nil {
+			return content, fm, e
+		} else if !found {
+			continue
+		}
+		m, e := format.unmarshal(block)
+		if e != nil {
+			return rest, fm, fmt.Errorf("invalid front matter: %w", e)
+		}
+		for k, v := range m {
+			fm[strings.ToLower(k)] = normalizeFrontMatterValue(v)
+		}
+		return rest, fm, nil
+	}
+	// no front matter, or a one line front matter
+	pureMarkdown, kv := ExtractFrontMatter(content)
+	for k, v := range kv {
+		fm[k] = v
+	}
+	return pureMarkdown, fm, nil
+}
+
+// cutFrontMatter cuts a front matter block enclosed by delimiter lines.
+func cutFrontMatter(content, delimiter string) (block, rest string, found bool, err error) {
+	isDelimiter := func(line string) bool { return strings.TrimRight(line, " \t\r") == delimiter }
+	first, after, _ := strings.Cut(content, "\n")
+	if !isDelimiter(first) {
+		return "", content, false, nil
+	}
+	for offset := 0; offset < len(after); {
+		line, _, _ := strings.Cut(after[offset:], "\n")
+		if isDelimiter(line) {
+			end := min(offset+len(line)+1, len(after))
+			return after[:offset], after[end:], true, nil
+		}
+		offset += len(line) + 1
+	}
+	return "", content, false, errors.New("front matter has no closing " + delimiter)
+}
+
+func unmarshalYAML(block string) (map[string]any, error) {
+	var m map[string]any
+	if e := yaml.Unmarshal([]byte(block), &m); e != nil {
+		return nil, e
+	}
+	return m, nil
+}
+
+// reTOMLLocalDate matches a value which is a local date, e.g. `date = 2024-01-02`.
+var reTOMLLocalDate = regexp.MustCompile(`(?m)^(\s*[\w."'-]+\s*=\s*\d{4}-\d{2}-\d{2})\r?$`)
+
+func unmarshalTOML(block string) (map[string]any, error) {
+	// go-toml v1 can't parse a local date at the end of a line, unless followed by a space
+	block = reTOMLLocalDate.ReplaceAllString(block, "$1 ")
+	tree, e := toml.Load(block)
+	if e != nil {
+		return nil, e
+	}
+	return tree.ToMap(), nil
+}
+
+// normalizeFrontMatterValue converts values decoded from YAML or TOML to the types of FrontMatter.
+func normalizeFrontMatterValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		fm := FrontMatter{}
+		for k, x := range v {
+			fm[k] = normalizeFrontMatterValue(x)
+		}
+		return fm
+	case map[any]any:
+		fm := FrontMatter{}
+		for k, x := range v {
+			fm[fmt.Sprint(k)] = normalizeFrontMatterValue(x)
+		}
+		return fm
+	case []any:
+		list := make([]any, len(v))
+		for i, x := range v {
+			list[i] = normalizeFrontMatterValue(x)
+		}
+		return list
+	case []map[string]any: // TOML arrays of tables
+		list := make([]any, len(v))
+		for i, x := range v {
+			list[i] = normalizeFrontMatterValue(x)
+		}
+		return list
+	case toml.LocalDate:
+		return v.In(time.UTC)
+	case toml.LocalDateTime:
+		return v.In(time.UTC)
+	case toml.LocalTime:
+		return v.String()
+	}
+	return v
+}
+
+// String returns the value of key as a string, "" if absent.
+func (fm FrontMatter) String(key string) string {
+	switch v := fm[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Strings returns the value of key as a list of strings: a list,
+// or a comma separated string such as "tag1, tag2" or "[tag1, tag2]".
+func (fm FrontMatter) Strings(key string) []string {
+	var list []string
+	switch v := fm[key].(type) {
+	case nil:
+	case []any:
+		for _, x := range v {
+			if s := strings.TrimSpace(FrontMatter{"": x}.String("")); s != "" {
+				list = append(list, s)
+			}
+		}
+	default:
+		s := strings.TrimSpace(fm.String(key))
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+		for _, item := range strings.Split(s, ",") {
+			item = strings.Trim(strings.TrimSpace(item), `"'`)
+			if item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// Bool returns the value of key as a boolean: true, or a string such as "true" or "yes".
+func (fm FrontMatter) Bool(key string) bool {
+	switch v := fm[key].(type) {
+	case bool:
+		return v
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "yes", "on":
+			return true
+		}
+		b, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return b
+	}
+	return false
+}
+
+// dateLayouts are the accepted formats of dates written as strings.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// Time returns the value of key as a time: a date, or a string in one of dateLayouts.
+func (fm FrontMatter) Time(key string) (time.Time, bool) {
+	switch v := fm[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		s := strings.Trim(strings.TrimSpace(v), `"'`)
+		for _, layout := range dateLayouts {
+			if t, e := time.Parse(layout, s); e == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Map returns the value of key as a nested front matter, nil if it is not a map.
+func (fm FrontMatter) Map(key string) FrontMatter {
+	m, _ := fm[key].(FrontMatter)
+	return m
+}
+
+// frontMatterOf is ParseFrontMatter, but when a YAML front matter is invalid,
+// its "key: value" lines are still read, like ExtractFrontMatter does.
+func frontMatterOf(logger *slog.Logger, file, content string) (pureMarkdown string, fm FrontMatter) {
+	pureMarkdown, fm, e := ParseFrontMatter(content)
+	if e != nil {
+		logger.Warn("front matter", "file", file, "error", e)
+		if strings.HasPrefix(content, "---") {
+			var kv map[string]string
+			pureMarkdown, kv = ExtractFrontMatter(content)
+			for k, v := range kv {
+				fm[k] = v
+			}
+		}
+	}
+	return pureMarkdown, fm
+}
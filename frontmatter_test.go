@@ -0,0 +1,115 @@
+package gnAsteroid
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		md, fm, e := ParseFrontMatter(`---
+Title: Document Title
+date: 2024-01-02
+tags: [tag1, tag2]
+draft: true
+weight: 3
+author:
+  name: Your Name
+---
+And the rest is markdown.
+`)
+		require.NoError(t, e)
+		assert.Equal(t, "And the rest is markdown.\n", md)
+		assert.Equal(t, "Document Title", fm.String("title"))
+		date, ok := fm.Time("date")
+		assert.True(t, ok)
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), date)
+		assert.Equal(t, "2024-01-02", fm.String("date"))
+		assert.Equal(t, []string{"tag1", "tag2"}, fm.Strings("tags"))
+		assert.True(t, fm.Bool("draft"))
+		assert.Equal(t, 3, fm["weight"])
+		assert.Equal(t, "Your Name", fm.Map("author").String("name"))
+	})
+	t.Run("toml", func(t *testing.T) {
+		md, fm, e := ParseFrontMatter("+++\r\ntitle = \"Document Title\"\r\ndate = 2024-01-02\r\nupdated = 2024-03-04T10:00:00Z\r\ntags = [\"tag1\", \"tag2\"]\r\ndraft = false\r\n[author]\r\nname = \"Your Name\"\r\n+++\r\nAnd the rest is markdown.")
+		require.NoError(t, e)
+		assert.Equal(t, "And the rest is markdown.", md)
+		assert.Equal(t, "Document Title", fm.String("title"))
+		date, ok := fm.Time("date")
+		assert.True(t, ok)
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), date)
+		updated, ok := fm.Time("updated")
+		assert.True(t, ok)
+		assert.Equal(t, "2024-03-04T10:00:00Z", updated.Format(time.RFC3339))
+		assert.Equal(t, []string{"tag1", "tag2"}, fm.Strings("tags"))
+		assert.False(t, fm.Bool("draft"))
+		assert.Equal(t, "Your Name", fm.Map("author").String("name"))
+	})
+	t.Run("one line", func(t *testing.T) {
+		md, fm, e := ParseFrontMatter("---title: What Is The Matrix---\nActual article")
+		require.NoError(t, e)
+		assert.Equal(t, "Actual article", md)
+		assert.Equal(t, FrontMatter{"title": "What Is The Matrix"}, fm)
+	})
+	t.Run("none", func(t *testing.T) {
+		for _, content := range []string{"", "foo", "# Title\n---\n"} {
+			md, fm, e := ParseFrontMatter(content)
+			require.NoError(t, e)
+			assert.Equal(t, content, md)
+			assert.Empty(t, fm)
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		md, fm, e := ParseFrontMatter("---\n---\nfoo")
+		require.NoError(t, e)
+		assert.Equal(t, "foo", md)
+		assert.Empty(t, fm)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		md, _, e := ParseFrontMatter("---\ntitle: [unclosed\n---\nfoo")
+		assert.Error(t, e)
+		assert.Equal(t, "foo", md)
+		_, _, e = ParseFrontMatter("+++\ntitle = \n+++\nfoo")
+		assert.Error(t, e)
+		md, _, e = ParseFrontMatter("---\ntitle: no end\nfoo")
+		assert.Error(t, e)
+		assert.Equal(t, "---\ntitle: no end\nfoo", md)
+	})
+}
+
+func TestFrontMatterAccessors(t *testing.T) {
+	fm := FrontMatter{
+		"list":   "[a, 'b', c]",
+		"csv":    "a, b",
+		"yes":    "yes",
+		"date":   "2024-02-01 12:30",
+		"number": 3.5,
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, fm.Strings("list"))
+	assert.Equal(t, []string{"a", "b"}, fm.Strings("csv"))
+	assert.Nil(t, fm.Strings("absent"))
+	assert.True(t, fm.Bool("yes"))
+	assert.False(t, fm.Bool("csv"))
+	date, ok := fm.Time("date")
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 2, 1, 12, 30, 0, 0, time.UTC), date)
+	_, ok = fm.Time("csv")
+	assert.False(t, ok)
+	assert.Equal(t, "3.5", fm.String("number"))
+	assert.Equal(t, "", fm.String("absent"))
+	assert.Nil(t, fm.Map("csv"))
+}
+
+func TestFrontMatterOf(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// invalid yaml still gives its key: value lines
+	md, fm := frontMatterOf(logger, "page.md", "---\ntitle: Colons: everywhere\ntags: [unclosed\n---\nfoo")
+	assert.Equal(t, "foo\n", md)
+	assert.Equal(t, "Colons: everywhere", fm.String("title"))
+	assert.Equal(t, []string{"unclosed"}, fm.Strings("tags"))
+}
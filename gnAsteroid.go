@@ -2,15 +2,16 @@ package gnAsteroid
 
 import (
 	"embed"
-	"html"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/gotuna/gotuna"
 	"github.com/yalue/merged_fs"
@@ -28,170 +29,425 @@ const DefaultTheme string = "themes/cloudy.theme"
 // realm-based wiki to have a local system of files that can
 // optionaly be served or backed up/restored to the blockchain.
 
-var (
-	asteroidName string // read from cmdLine, or file called .TITLE at root, or "CHANGEME"
-	asteroidFs   fs.FS  // used by HandleRootAsMdFile and HandleNotFoundAsFile. This is the main difference with gno.land
-)
+// Asteroid is a markdown-based website served by gnoweb.
+// Each Asteroid builds its own independent http.Handler, so several of them
+// can be served by the same process.
+type Asteroid struct {
+	FS      fs.FS            // the tree to serve (normally some markdown documents). This is the main difference with gno.land
+	Name    string           // website title, e.g. read from cmdLine, or file called .TITLE at root
+	Prefix  string           // url path the asteroid is served under, e.g. "/wiki". The root if empty
+	ThemeFS fs.FS            // css/, font/, img/ and optional views/ (see ThemeViewsDir). If nil, os.DirFS(DefaultTheme) will be used
+	Themes  map[string]fs.FS // named themes readers can pick (see SettingsFile), e.g. "raw"
+	Config  *Config          // if nil, NewDefaultConfig() will be used
+	Chain   *ChainClient     // queries the gnoland node, e.g. shared by the handlers of several asteroids. If nil, each handler makes one from Config
+	Logger  *slog.Logger     // if nil, slog.Default() will be used
+
+	FeedDirs   []string // directories (e.g. "blog") whose dated pages are in /feed.xml, /atom.xml and /feed.json. All if empty
+	Taxonomies []string // front matter lists (e.g. "tags", "series") whose terms are listed at /tags/, /series/. DefaultTaxonomies if nil
+	DirIndex   bool     // list the pages of directories having neither index.md nor README.md, instead of "Not Found"
+
+	Drafts             bool     // publish the pages having "draft: true" in their front matter, e.g. to preview them
+	AssetExtensions    []string // extensions of the files served as is, e.g. ".pdf". DefaultAssetExtensions if nil
+	DownloadExtensions []string // extensions of the assets served as attachments, e.g. ".zip"
+}
+
+// std is the asteroid used by the package-level functions
+// (SetAsteroidFs, SetAsteroidName, MakeApp, HandleRootAsMdFile, HandleNotFoundAsFile).
+var std = &Asteroid{}
 
 //go:embed views/*.html
 var newViews embed.FS // composed with gnoweb's, using merged_fs
 
-func SetAsteroidFs(asteroid fs.FS) { asteroidFs = asteroid }
-func SetAsteroidName(name string)  { asteroidName = name }
+func SetAsteroidFs(asteroid fs.FS) { std.FS = asteroid }
+func SetAsteroidName(name string)  { std.Name = name }
+
+// NewAsteroid returns an asteroid serving asteroidFs, with default theme, Config and logger.
+func NewAsteroid(asteroidFs fs.FS, name string) *Asteroid {
+	return &Asteroid{FS: asteroidFs, Name: name}
+}
 
 // HandleAsteroid can be used to have a serverless gnoweb serving an asteroid as a handler.
 // This can be used for example on Vercel.
 // @param (asteroid) the fs to serve (normally a tree with some markdown documents)
 // @param (theme) if nil, os.DirFS(DefaultTheme) will be used
 func HandleAsteroid(asteroid, theme fs.FS, asteroidName_ string, cfg *Config) http.Handler {
-	SetAsteroidFs(asteroid)
-	SetAsteroidName(asteroidName_)
-	return MakeApp(slog.Default(), cfg, theme)
+	return (&Asteroid{
+		FS:      asteroid,
+		Name:    asteroidName_,
+		ThemeFS: theme,
+		Config:  cfg,
+	}).Handler()
 }
 
 // MakeApp is separated from HandleAsteroid, mainly because
 // cmd/main uses MakeApp(), to reload watched files.
+// It serves the asteroid set with SetAsteroidFs and SetAsteroidName.
+// If it can't be served, the error is served as an error page, see NewApp.
 func MakeApp(logger *slog.Logger, cfg *Config, themeFs fs.FS) http.Handler {
-	gnowebViews, e := fs.Sub(DefaultViewsFiles(), "views")
-	if e != nil {
-		panic("Could not find gnoweb views: " + e.Error())
+	return (&Asteroid{
+		FS:      std.FS,
+		Name:    std.Name,
+		ThemeFS: themeFs,
+		Config:  cfg,
+		Logger:  logger,
+	}).Handler()
+}
+
+// NewApp is like MakeApp, but returns an error if the asteroid can't be served,
+// e.g. ErrNoIndex.
+func NewApp(logger *slog.Logger, cfg *Config, themeFs fs.FS) (http.Handler, error) {
+	return (&Asteroid{
+		FS:      std.FS,
+		Name:    std.Name,
+		ThemeFS: themeFs,
+		Config:  cfg,
+		Logger:  logger,
+	}).NewHandler()
+}
+
+// shadowed reports whether the asteroid has a file or directory at name, e.g. "tags",
+// which the pages generated at /name (e.g. the taxonomy "tags") would shadow: they are not served then.
+func (a *Asteroid) shadowed(name string) bool {
+	if _, e := fs.Stat(a.FS, name); e != nil {
+		return false
 	}
-	asteroidViews, e := fs.Sub(newViews, "views")
+	a.logger().Warn("/"+name+" is served from the asteroid, rather than generated", "path", name)
+	return true
+}
+
+// ErrNoIndex is returned for asteroids having neither index.md nor README.md at their root,
+// unless it is listed (see Asteroid.DirIndex).
+var ErrNoIndex = errors.New("asteroid must include /(index|README).md")
+
+// requiredViews are checked by NewHandler, for pages not to fail at request time.
+var requiredViews = []string{"funcs.html", "asteroid_markdown.html", "403.html", "404.html", "500.html", "502.html", "504.html"}
+
+// Handler returns a new gnoweb handler serving the asteroid, but its unpublished files (see IgnoreFile).
+// It can be called again to take changes on disk into account.
+// If the asteroid can't be served (see NewHandler), the error is logged,
+// and served as an error page, which doesn't tell it.
+func (a *Asteroid) Handler() http.Handler {
+	handler, e := a.NewHandler()
 	if e != nil {
-		panic("Could not find asteroid views: " + e.Error())
+		a.logger().Error("serving the asteroid", "error", e)
+		if handler == nil {
+			return http.HandlerFunc(serveUnservable)
+		}
+	}
+	return handler
+}
+
+// NewHandler is like Handler, but returns an error if the asteroid can't be
+// served, e.g. ErrNoIndex. Then the handler, if not nil, serves the other pages,
+// and a themed error page at the root.
+func (a *Asteroid) NewHandler() (http.Handler, error) {
+	themes := a.themeChoice(loadSettings(a.logger(), a.FS))
+	a = a.published()
+	if a.Config == nil || a.Chain == nil { // a single Config and ChainClient, shared by the routers
+		b := *a
+		if b.Config == nil {
+			b.Config = NewDefaultConfig()
+		}
+		if b.Chain == nil {
+			b.Chain = NewChainClient(b.logger(), b.Config)
+		}
+		a = &b
 	}
+	themeFs := a.ThemeFS
 	if themeFs == nil {
 		themeFs = os.DirFS(DefaultTheme)
 	}
-	return MakeGnowebAppWithOptions(logger, cfg, Options{
-		RootHandler:     HandleRootAsMdFile,
-		NotFoundHandler: HandleNotFoundAsFile,
-		ThemeFS:         themeFs,
-		ViewFS:          merged_fs.NewMergedFS(asteroidViews, gnowebViews),
-	}).Router
+	themes.schemes = map[string][]string{"": a.colorSchemes(themeFs)}
+	for _, name := range themes.names {
+		themes.schemes[name] = a.colorSchemes(a.Themes[name])
+	}
+	views, e := a.views(themeFs)
+	if e != nil {
+		return nil, e
+	}
+	pages := loadPages(a.logger(), a.FS)
+	index := newSearchIndex(pages, a.Name)
+	items := newFeedItems(pages)
+	search, searchJSON := !a.shadowed("search"), !a.shadowed("search.json")
+	shadowedFeeds := map[string]bool{}
+	for file := range feedFiles {
+		shadowedFeeds[file] = a.shadowed(file)
+	}
+	var taxonomies []string
+	for _, name := range a.taxonomies() {
+		if !a.shadowed(name) {
+			taxonomies = append(taxonomies, name)
+		}
+	}
+	var rootErr error
+	newRouter := func(views fs.FS) http.Handler {
+		app := MakeGnowebAppWithOptions(a.logger(), a.config(), Options{
+			RootHandler: func(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+				var root http.Handler
+				if root, rootErr = a.handleRoot(logger, app, cfg); rootErr != nil {
+					return handleError(logger, app, cfg, rootErr)
+				}
+				return root
+			},
+			NotFoundHandler: a.HandleNotFoundAsFile,
+			ThemeFS:         themeFs,
+			Themes:          a.Themes,
+			ViewFS:          views,
+			ViewHelpers:     themes.viewHelpers(),
+			Chain:           a.Chain,
+		})
+		if search {
+			app.Router.Handle("/search", a.handleSearch(index, app, a.config()))
+		}
+		if searchJSON {
+			app.Router.Handle("/search.json", a.handleSearchJSON(index))
+		}
+		for file, format := range feedFiles {
+			if !shadowedFeeds[file] {
+				app.Router.Handle("/"+file, a.handleFeed(items, format, app, a.config()))
+			}
+			app.Router.Handle("/{dir:.+}/"+file, a.handleFeed(items, format, app, a.config()))
+		}
+		for _, name := range taxonomies {
+			handler := a.handleTaxonomy(newTaxonomy(pages, name), app, a.config())
+			for _, route := range []string{"/" + name, "/" + name + "/", "/" + name + "/{term}", "/" + name + "/{term}/"} {
+				app.Router.Handle(route, handler)
+			}
+		}
+		return app.Router
+	}
+	router := newRouter(views)
+
+	// views are parsed by the routers, so the themes having their own views have their own router
+	var base http.Handler // router of the other themes, if ThemeFS has views
+	routers := map[string]http.Handler{}
+	for _, name := range themes.names {
+		switch theme := a.Themes[name]; {
+		case hasViews(theme):
+			views, e := a.views(theme)
+			if e != nil {
+				a.logger().Warn("views of theme "+name+" ignored", "error", e)
+				continue
+			}
+			routers[name] = newRouter(views)
+		case hasViews(themeFs):
+			if base == nil {
+				views, e := a.views(nil)
+				if e != nil {
+					return nil, e
+				}
+				base = newRouter(views)
+			}
+			routers[name] = base
+		}
+	}
+	return withPrefix(a.Prefix, themes.handler(router, routers)), rootErr
 }
 
-// This RootHandler has gnoweb serve a file called "index.md" or "README.md" when root is requested
-// XXX at a glance it seems possible to use the logic from HandleNotFoundAsFile instead.
-func HandleRootAsMdFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
-	var rootFile fs.File
-	var e error
-	// this can get called again when served files change on disk
-	// some editors like vim can take a long time to actually save and make a file
-	// available, hence we wait a bit (e.g. 0, 20ms, 100ms), to ultimately fail if nec.
-	found := false
-	for _, ms := range []time.Duration{20, 100} {
-		rootFile, e = asteroidFs.Open("index.md")
+// hasViews tells whether theme has a views/ directory, see Asteroid.views.
+func hasViews(theme fs.FS) bool {
+	stat, e := fs.Stat(theme, ThemeViewsDir)
+	return e == nil && stat.IsDir()
+}
+
+// views returns the views of theme, if it has a ThemeViewsDir (theme may be nil),
+// layered on top of the asteroid views, themselves layered on top of gnoweb views.
+func (a *Asteroid) views(theme fs.FS) (fs.FS, error) {
+	gnowebViews, e := fs.Sub(DefaultViewsFiles(), "views")
+	if e != nil {
+		return nil, fmt.Errorf("could not find gnoweb views: %w", e)
+	}
+	asteroidViews, e := fs.Sub(newViews, "views")
+	if e != nil {
+		return nil, fmt.Errorf("could not find asteroid views: %w", e)
+	}
+	var views fs.FS = merged_fs.NewMergedFS(asteroidViews, gnowebViews)
+	if theme != nil && hasViews(theme) {
+		themeViews, e := fs.Sub(theme, ThemeViewsDir)
 		if e != nil {
-			rootFile, e = asteroidFs.Open("README.md")
+			return nil, fmt.Errorf("could not find theme views: %w", e)
 		}
-		if e == nil {
-			found = true
-			break
+		views = merged_fs.NewMergedFS(themeViews, views)
+	}
+	for _, name := range requiredViews {
+		if _, e := fs.Stat(views, name); e != nil {
+			return nil, fmt.Errorf("could not find view: %w", e)
 		}
-		time.Sleep(ms * time.Millisecond)
 	}
-	if !found {
-		panic("asteroid must include /(index|README).md")
+	return views, nil
+}
+
+func (a *Asteroid) config() *Config {
+	if a.Config == nil {
+		return NewDefaultConfig()
+	}
+	return a.Config
+}
+
+func (a *Asteroid) logger() *slog.Logger {
+	if a.Logger == nil {
+		return slog.Default()
 	}
-	fileInfo, _ := rootFile.Stat()
-	buf := make([]byte, fileInfo.Size())
-	_, e = rootFile.Read(buf)
+	return a.Logger
+}
+
+// HandleRootAsMdFile serves "index.md" or "README.md" of the asteroid set with SetAsteroidFs.
+func HandleRootAsMdFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+	return std.published().HandleRootAsMdFile(logger, app, cfg)
+}
+
+// HandleNotFoundAsFile serves markdown or images of the asteroid set with SetAsteroidFs.
+func HandleNotFoundAsFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+	return std.published().HandleNotFoundAsFile(logger, app, cfg)
+}
+
+// This RootHandler has gnoweb serve a file called "index.md" or "README.md" when root is requested.
+// If there is none, it serves an error page.
+// XXX at a glance it seems possible to use the logic from HandleNotFoundAsFile instead.
+func (a *Asteroid) HandleRootAsMdFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+	root, e := a.handleRoot(logger, app, cfg)
 	if e != nil {
-		panic(e.Error())
+		logger.Error("serving the asteroid", "error", e)
+		return handleError(logger, app, cfg, e)
+	}
+	return root
+}
+
+// handleRoot serves "index.md" or "README.md", read at once,
+// or returns ErrNoIndex if there is none, unless the root is listed (see Asteroid.DirIndex).
+// Files being saved, e.g. removed then written again by editors, are
+// left to cmd/main, which waits for them to stop changing before reloading.
+func (a *Asteroid) handleRoot(logger *slog.Logger, app gotuna.App, cfg *Config) (http.Handler, error) {
+	name := "index.md"
+	content, e := fs.ReadFile(a.FS, name)
+	if errors.Is(e, fs.ErrNotExist) {
+		name = "README.md"
+		content, e = fs.ReadFile(a.FS, name)
+	}
+	if errors.Is(e, fs.ErrNotExist) {
+		if a.DirIndex {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				a.serveDirIndex(w, r, app, cfg, ".")
+			}), nil
+		}
+		return nil, ErrNoIndex
+	} else if e != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, e)
 	}
 	// Filter out optional Front Matter
 	// extracting document Title, if absent Title is the url's path
 	// page title is asteroid name, unless defined in Front Matter
-	pureMarkdown, _ := ExtractFrontMatter(string(buf))
-	pageName := asteroidName
+	pureMarkdown, fm := frontMatterOf(logger, name, string(content))
+	pageName := a.Name
+	html, e := renderMarkdown(pureMarkdown, asteroidPolicy)
+	if e != nil {
+		return nil, fmt.Errorf("rendering %s: %w", name, e)
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.NewTemplatingEngine().
-			Set("AsteroidName", asteroidName).
+			Set("AsteroidName", a.Name).
 			Set("AtHome", "1"). // to e.g. disable back_button
 			Set("PageName", pageName).
+			Set("FrontMatter", fm).
 			Set("Content", string(pureMarkdown)).
+			Set("HTML", prefixLinks(html, basePath(r))).
 			Set("Config", cfg).
 			Render(w, r, "asteroid_markdown.html", "funcs.html")
-	})
+	}), nil
 }
 
-// HandleNotFoundAsFile is a fallthrough handler to attempt serving markdown or images
-func HandleNotFoundAsFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+// handleError serves the error page of e, e.g. ErrNoIndex, whatever the request.
+func handleError(logger *slog.Logger, app gotuna.App, cfg *Config, e error) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		url := r.URL.String()
-		url = html.UnescapeString(url)
-		url = strings.TrimPrefix(url, "/") // with embedfs, need to remove the leading /
-		url = filepath.Clean(url)
+		renderError(logger, app, cfg, w, r, http.StatusInternalServerError, e)
+	})
+}
 
-		if strings.Contains(url, "..") {
-			app.NewTemplatingEngine().
-				Set("AsteroidName", asteroidName).
-				Set("Config", cfg).
-				Render(w, r, "403.html", "funcs.html")
+// HandleNotFoundAsFile is a fallthrough handler to attempt serving markdown or assets (see isAsset)
+func (a *Asteroid) HandleNotFoundAsFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		url, e := resolvePath(r.URL.Path) // e.g. "subdir/page.md"
+		if e != nil {
+			renderError(logger, app, cfg, w, r, http.StatusForbidden, nil)
+			return
+		} else if isHidden(url) {
+			a.notFound(w, r, app, cfg)
 			return
 		}
 		var file fs.File // when nil, means still not found
 		servedFilename := ""
 
-		for _, path := range []string{
-			url + "/index.md",
-			url + "/README.md",
+		for _, name := range []string{
+			path.Join(url, "index.md"),
+			path.Join(url, "README.md"),
 			url,
 		} {
-			x, e := asteroidFs.Open(path)
-			if e == nil {
-				if stat, e := x.Stat(); e == nil && !stat.IsDir() {
-					file = x
-					servedFilename = path
-					break
-				}
+			x, e := a.FS.Open(name)
+			if e != nil {
+				continue
+			}
+			if stat, e := x.Stat(); e == nil && !stat.IsDir() {
+				file = x
+				servedFilename = name
+				break
 			}
+			x.Close()
 		}
 		if file == nil {
-			http.Error(w, "Not Found: "+url, http.StatusNotFound)
+			if stat, e := fs.Stat(a.FS, url); a.DirIndex && e == nil && stat.IsDir() {
+				a.serveDirIndex(w, r, app, cfg, url)
+				return
+			}
+			a.notFound(w, r, app, cfg)
 			return
 		}
+		defer file.Close()
 		// serve based on file extension
 		switch {
 		case strings.HasSuffix(servedFilename, ".md"):
 			stat, e := file.Stat()
 			if e != nil {
-				http.Error(w, "can not stat file", http.StatusExpectationFailed)
+				renderError(logger, app, cfg, w, r, http.StatusInternalServerError, e)
 				return
 			}
 			content := make([]byte, stat.Size())
-			if _, e := file.Read(content); e != nil {
-				http.Error(w, "can not read file: error", http.StatusExpectationFailed)
+			if _, e := io.ReadFull(file, content); e != nil {
+				renderError(logger, app, cfg, w, r, http.StatusInternalServerError, fmt.Errorf("reading %s: %w", servedFilename, e))
 				return
 			}
 			// Filter out optional Front Matter
 			// extracting document Title, if absent Title is the url's path
-			pureMarkdown, kv := ExtractFrontMatter(string(content))
+			pureMarkdown, fm := frontMatterOf(logger, servedFilename, string(content))
 			pageName := strings.TrimSuffix(url, ".md") // e.g. "subdir/deep/blue" (without .md)
-			if title, has := kv["title"]; has {
+			if title := fm.String("title"); title != "" {
 				pageName = title
 			}
+			html, e := renderMarkdown(pureMarkdown, asteroidPolicy)
+			if e != nil {
+				renderError(logger, app, cfg, w, r, http.StatusInternalServerError, fmt.Errorf("rendering %s: %w", servedFilename, e))
+				return
+			}
 			app.NewTemplatingEngine().
-				Set("AsteroidName", asteroidName).
+				Set("AsteroidName", a.Name).
 				Set("AtHome", "0"). // to e.g. allow back_button
 				Set("PageName", pageName).
+				Set("FrontMatter", fm).
 				Set("Content", string(pureMarkdown)).
+				Set("HTML", prefixLinks(html, basePath(r))).
 				Set("Config", cfg).
 				Render(w, r, "funcs.html", "asteroid_markdown.html")
-		case strings.HasSuffix(servedFilename, ".jpg"),
-			strings.HasSuffix(servedFilename, ".jpeg"),
-			strings.HasSuffix(servedFilename, ".png"),
-			strings.HasSuffix(servedFilename, ".gif"),
-			strings.HasSuffix(servedFilename, ".svg"),
-			strings.HasSuffix(servedFilename, ".webp"):
-			http.ServeFileFS(w, r, asteroidFs, servedFilename)
+		case a.isAsset(servedFilename):
+			a.serveAsset(w, r, app, cfg, file, servedFilename)
 		default:
-			http.Error(w, "Unrecognized extension", http.StatusExpectationFailed)
+			// not allowed, e.g. scripts or keys lying in the asteroid
+			a.notFound(w, r, app, cfg)
 		}
 	})
 }
 
+// ExtractFrontMatter is the former, lenient front matter parser, kept for
+// compatibility: values are raw strings. See ParseFrontMatter for typed values.
+//
 // given a `content` supposedly in markdown,
 // return `pureMarkdown` which is the same as `content` without a Front Matter
 // header.
@@ -3,8 +3,10 @@ package gnAsteroid
 import (
 	"embed"
 	"io/fs"
+	"net/http"
 	"os"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,18 +17,65 @@ var neptune embed.FS
 func TestAsteroid(t *testing.T) {
 	neptuneFs, _ := fs.Sub(neptune, "example")
 
-	if nil == HandleAsteroid(
+	handler := HandleAsteroid(
 		neptuneFs, os.DirFS(DefaultTheme), "neptune as an asteroid",
 		&Config{
-			RemoteAddr:  "gno.land:26657",
+			RemoteAddr:  mockChain(t),
 			HelpChainID: "portal-loop",
 			HelpRemote:  "gno.land:26657",
 		},
-	) {
-		t.Error("nil handler")
+	)
+	if nil == handler {
+		t.Fatal("nil handler")
 	}
 
-	// TODO add serving test
+	for _, tc := range []struct {
+		route     string
+		status    int
+		substring string
+	}{
+		{"/", http.StatusOK, "neptune as an asteroid"},
+		{"/r/demo/users", http.StatusOK, "administrator"},
+		{"/p/demo/avl/", http.StatusOK, "avl.gno"},
+		{"/not-there.md", http.StatusNotFound, ""},
+	} {
+		response := serveRoute(t, handler, tc.route, nil)
+		assert.Equal(t, tc.status, response.Code, tc.route)
+		assert.Contains(t, response.Body.String(), tc.substring, tc.route)
+	}
+}
+
+// two asteroids served by the same process must not share anything
+func TestMultipleAsteroids(t *testing.T) {
+	mercury := NewAsteroid(fstest.MapFS{
+		"index.md": {Data: []byte("mercury home")},
+		"hot.md":   {Data: []byte("closest to the sun")},
+	}, "Mercury")
+	pluto := NewAsteroid(fstest.MapFS{
+		"README.md": {Data: []byte("pluto home")},
+		"cold.md":   {Data: []byte("not a planet anymore")},
+	}, "Pluto")
+	mercuryHandler, plutoHandler := mercury.Handler(), pluto.Handler()
+
+	for _, tc := range []struct {
+		handler   http.Handler
+		route     string
+		status    int
+		substring string
+	}{
+		{mercuryHandler, "/", http.StatusOK, "mercury home"},
+		{mercuryHandler, "/", http.StatusOK, "Mercury"},
+		{mercuryHandler, "/hot.md", http.StatusOK, "closest to the sun"},
+		{mercuryHandler, "/cold.md", http.StatusNotFound, ""},
+		{plutoHandler, "/", http.StatusOK, "pluto home"},
+		{plutoHandler, "/", http.StatusOK, "Pluto"},
+		{plutoHandler, "/cold.md", http.StatusOK, "not a planet anymore"},
+		{plutoHandler, "/hot.md", http.StatusNotFound, ""},
+	} {
+		response := serveRoute(t, tc.handler, tc.route, nil)
+		assert.Equal(t, tc.status, response.Code, tc.route)
+		assert.Contains(t, response.Body.String(), tc.substring, tc.route)
+	}
 }
 
 func TestExtractFrontMatter(t *testing.T) {
@@ -60,3 +109,27 @@ amigo`
 		assert.Equal(t, md, "Actual article")
 	}
 }
+
+// an asteroid without index.md is served, with an error page at its root
+func TestNoIndex(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{
+		"page.md": {Data: []byte("still here")},
+	}, "Ceres")
+	handler, e := asteroid.NewHandler()
+	assert.ErrorIs(t, e, ErrNoIndex)
+	for _, h := range []http.Handler{handler, asteroid.Handler()} {
+		response := serveRoute(t, h, "/", nil)
+		assert.Equal(t, http.StatusInternalServerError, response.Code)
+		assert.NotContains(t, response.Body.String(), ErrNoIndex.Error(), "logged, not shown")
+		assert.Contains(t, response.Body.String(), "/static/css/", "themed")
+
+		response = serveRoute(t, h, "/page.md", nil)
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Body.String(), "still here")
+	}
+
+	// an empty index.md is fine
+	handler, e = NewAsteroid(fstest.MapFS{"index.md": {}}, "Ceres").NewHandler()
+	assert.NoError(t, e)
+	assert.Equal(t, http.StatusOK, serveRoute(t, handler, "/", nil).Code)
+}
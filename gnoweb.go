@@ -12,7 +12,6 @@ import (
 	"bytes"
 	"embed"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -27,9 +26,9 @@ import (
 
 	"github.com/gnolang/gno/tm2/pkg/amino"
 	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
-	"github.com/gnolang/gno/tm2/pkg/bft/rpc/client"
 	"github.com/gorilla/mux"
 	"github.com/gotuna/gotuna"
+	"github.com/yalue/merged_fs"
 
 	"github.com/gnAsteroid/gnAsteroid/static"
 	"github.com/gnolang/gno/gno.land/pkg/sdk/vm" // for error types
@@ -43,11 +42,16 @@ const (
 var defaultViewsFiles embed.FS // getter: DefaultViewsFiles()
 
 type Config struct {
-	RemoteAddr    string
-	ViewsDir      string
-	HelpChainID   string
-	HelpRemote    string
-	WithAnalytics bool
+	RemoteAddr         string
+	ViewsDir           string
+	HelpChainID        string
+	HelpRemote         string
+	WithAnalytics      bool
+	ClientSideMarkdown bool          // render markdown in the browser (marked.js), instead of on the server
+	QueryTimeout       time.Duration // timeout of each query to RemoteAddr, 10s if zero
+	Cache              CacheConfig   // caching of query results, disabled if zero (the default), e.g. NewDefaultCacheConfig()
+	SnapshotDir        string        // where realm and package query results are saved, to be served when the chain is unreachable. Disabled if empty
+	LiveReload         string        // url path of a Server-Sent Events stream of "reload" and "css" events, refreshing open pages, under the prefix of the asteroid. Disabled if empty
 }
 
 type Options struct {
@@ -57,15 +61,39 @@ type Options struct {
 	NotFoundHandler func(*slog.Logger, gotuna.App, *Config) http.Handler // if set, will be used instead of default notFoundHandler
 	ViewFS          fs.FS                                                // if set, has precedence over ViewsDir
 	ThemeFS         fs.FS                                                // optional theme directory (containing css/, img/ and font/)
+	Themes          map[string]fs.FS                                     // optional named themes, served at /static/themes/<name>/
+	ViewHelpers     []gotuna.ViewHelperFunc                              // template functions, e.g. replacing the defaultViewHelpers
+	Chain           *ChainClient                                         // queries the gnoland node. If nil, one is made from the Config
+}
+
+// defaultViewHelpers are the template functions of the views:
+// themePath is the url path of the theme files, basePath the prefix of the asteroid (e.g. "/wiki"),
+// themes the options of the theme selector,
+// colorSchemes the color schemes of the theme (see Manifest.ColorSchemes).
+var defaultViewHelpers = []gotuna.ViewHelperFunc{
+	func(w http.ResponseWriter, r *http.Request) (string, interface{}) {
+		return "themePath", func() string { return basePath(r) + "/static/" }
+	},
+	func(w http.ResponseWriter, r *http.Request) (string, interface{}) {
+		return "basePath", func() string { return basePath(r) }
+	},
+	func(w http.ResponseWriter, r *http.Request) (string, interface{}) {
+		return "themes", func() []themeOption { return nil }
+	},
+	func(w http.ResponseWriter, r *http.Request) (string, interface{}) {
+		return "colorSchemes", func() []string { return nil }
+	},
 }
 
 func NewDefaultConfig() *Config {
 	return &Config{
-		RemoteAddr:    "127.0.0.1:26657",
-		ViewsDir:      "",
-		HelpChainID:   "dev",
-		HelpRemote:    "127.0.0.1:26657",
-		WithAnalytics: false,
+		RemoteAddr:         "127.0.0.1:26657",
+		ViewsDir:           "",
+		HelpChainID:        "dev",
+		HelpRemote:         "127.0.0.1:26657",
+		WithAnalytics:      false,
+		ClientSideMarkdown: false,
+		QueryTimeout:       defaultQueryTimeout,
 	}
 }
 
@@ -90,13 +118,18 @@ func MakeGnowebAppWithOptions(logger *slog.Logger, cfg *Config, opts Options) go
 	}
 
 	app := gotuna.App{
-		ViewFiles: viewFiles,
-		Router:    gotuna.NewMuxRouter(),
-		Static:    static.EmbeddedStatic,
+		ViewFiles:   viewFiles,
+		Router:      gotuna.NewMuxRouter(),
+		Static:      static.EmbeddedStatic,
+		ViewHelpers: append(append([]gotuna.ViewHelperFunc{}, defaultViewHelpers...), opts.ViewHelpers...),
+	}
+	chain := opts.Chain
+	if chain == nil {
+		chain = NewChainClient(logger, cfg)
 	}
 
 	for from, to := range opts.Aliases {
-		app.Router.Handle(from, handlerRealmAlias(logger, app, cfg, to))
+		app.Router.Handle(from, handlerRealmAlias(logger, app, cfg, chain, to))
 	}
 	for from, to := range opts.Redirects {
 		app.Router.Handle(from, handlerRedirect(logger, app, cfg, to))
@@ -106,33 +139,34 @@ func MakeGnowebAppWithOptions(logger *slog.Logger, cfg *Config, opts Options) go
 	}
 	// realm routes
 	// NOTE: see rePathPart.
-	app.Router.Handle("/r/{rlmname:[a-z][a-z0-9_]*(?:/[a-z][a-z0-9_]*)+}/{filename:(?:(?:.*\\.(?:gno|md|txt|mod)$)|(?:LICENSE$))?}", handlerRealmFile(logger, app, cfg))
-	app.Router.Handle("/r/{rlmname:[a-z][a-z0-9_]*(?:/[a-z][a-z0-9_]*)+}", handlerRealmMain(logger, app, cfg))
-	app.Router.Handle("/r/{rlmname:[a-z][a-z0-9_]*(?:/[a-z][a-z0-9_]*)+}:{querystr:.*}", handlerRealmRender(logger, app, cfg))
-	app.Router.Handle("/p/{filepath:.*}", handlerPackageFile(logger, app, cfg))
+	app.Router.Handle("/r/{rlmname:[a-z][a-z0-9_]*(?:/[a-z][a-z0-9_]*)+}/{filename:(?:(?:.*\\.(?:gno|md|txt|mod)$)|(?:LICENSE$))?}", handlerRealmFile(logger, app, cfg, chain))
+	app.Router.Handle("/r/{rlmname:[a-z][a-z0-9_]*(?:/[a-z][a-z0-9_]*)+}", handlerRealmMain(logger, app, cfg, chain))
+	app.Router.Handle("/r/{rlmname:[a-z][a-z0-9_]*(?:/[a-z][a-z0-9_]*)+}:{querystr:.*}", handlerRealmRender(logger, app, cfg, chain))
+	app.Router.Handle("/p/{filepath:.*}", handlerPackageFile(logger, app, cfg, chain))
 
 	// other
 	app.Router.Handle("/faucet", handlerFaucet(logger, app, cfg))
 
 	if themeFiles != nil {
 		// alternative styling for css, font and img.
-		// if assets are not found here, static/* assets are
-		// still handled by the next line after this if-block
-		// which works as a fallthrough.
-		app.Router.Handle("/static/{path:(?:css|font|img)/.+}", handlerStaticFile(logger, app, cfg, themeFiles))
+		// files missing from the theme are those of static/, e.g. css/components.css
+		app.Router.Handle("/static/{path:(?:css|font|img)/.+}", handlerStaticFile(logger, app, cfg, merged_fs.NewMergedFS(themeFiles, app.Static)))
+	}
+	for name, theme := range opts.Themes {
+		// files missing from the theme are those of static/
+		app.Router.Handle("/static/themes/"+name+"/{path:(?:css|font|img)/.+}", handlerThemeFile(logger, app, cfg, name, merged_fs.NewMergedFS(theme, app.Static)))
 	}
 	app.Router.Handle("/static/{path:.+}", handlerStaticFile(logger, app, cfg, app.Static))
 	app.Router.Handle("/favicon.ico", handlerFavicon(logger, app, cfg))
 
 	// api
-	app.Router.Handle("/status.json", handlerStatusJSON(logger, app, cfg))
+	app.Router.Handle("/status.json", handlerStatusJSON(logger, app, cfg, chain))
 
 	if opts.NotFoundHandler != nil {
 		app.Router.NotFoundHandler = opts.NotFoundHandler(logger, app, cfg)
 	} else {
 		app.Router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			path := r.RequestURI
-			handleNotFound(logger, app, cfg, path, w, r)
+			handleNotFound(logger, app, cfg, w, r)
 		})
 	}
 
@@ -143,7 +177,7 @@ func MakeGnowebAppWithOptions(logger *slog.Logger, cfg *Config, opts Options) go
 // url is intended to be shorter.
 // UX is intended to be more minimalistic.
 // A link to the realm realm is added.
-func handlerRealmAlias(logger *slog.Logger, app gotuna.App, cfg *Config, rlmpath string) http.Handler {
+func handlerRealmAlias(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient, rlmpath string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rlmfullpath := "gno.land" + rlmpath
 		querystr := "" // XXX: "?gnoweb-alias=1"
@@ -159,9 +193,15 @@ func handlerRealmAlias(logger *slog.Logger, app gotuna.App, cfg *Config, rlmpath
 		rlmname := strings.TrimPrefix(rlmfullpath, "gno.land/r/")
 		qpath := "vm/qrender"
 		data := []byte(fmt.Sprintf("%s:%s", rlmfullpath, querystr))
-		res, err := makeRequest(logger, cfg, qpath, data)
+		res, err := chain.makeRequest(r.Context(), qpath, data)
 		if err != nil {
-			writeError(logger, w, fmt.Errorf("gnoweb failed to query gnoland: %w", err))
+			writeError(logger, app, cfg, w, r, fmt.Errorf("gnoweb failed to query gnoland: %w", err))
+			return
+		}
+
+		html, err := renderMarkdown(string(res.Data), realmPolicy)
+		if err != nil {
+			writeError(logger, app, cfg, w, r, err)
 			return
 		}
 
@@ -169,7 +209,7 @@ func handlerRealmAlias(logger *slog.Logger, app gotuna.App, cfg *Config, rlmpath
 		pathLinks := []pathLink{}
 		for i, part := range queryParts {
 			pathLinks = append(pathLinks, pathLink{
-				URL:  "/r/" + rlmname + ":" + strings.Join(queryParts[:i+1], "/"),
+				URL:  basePath(r) + "/r/" + rlmname + ":" + strings.Join(queryParts[:i+1], "/"),
 				Text: part,
 			})
 		}
@@ -182,8 +222,10 @@ func handlerRealmAlias(logger *slog.Logger, app gotuna.App, cfg *Config, rlmpath
 		tmpl.Set("Query", querystr)
 		tmpl.Set("PathLinks", pathLinks)
 		tmpl.Set("Contents", string(res.Data))
+		tmpl.Set("HTML", prefixLinks(html, basePath(r)))
 		tmpl.Set("Config", cfg)
 		tmpl.Set("IsAlias", true)
+		tmpl.Set("Snapshot", res.Snapshot)
 		tmpl.Render(w, r, "realm_render.html", "funcs.html")
 	})
 }
@@ -196,7 +238,7 @@ func handlerFaucet(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handle
 	})
 }
 
-func handlerStatusJSON(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+func handlerStatusJSON(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient) http.Handler {
 	startedAt := time.Now()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var ret struct {
@@ -230,7 +272,7 @@ func handlerStatusJSON(logger *slog.Logger, app gotuna.App, cfg *Config) http.Ha
 		ret.Website.GoVersion = runtime.Version()
 
 		ret.Gnoland.Connected = true
-		res, err := makeRequest(logger, cfg, ".app/version", []byte{})
+		res, err := chain.makeRequest(r.Context(), ".app/version", []byte{})
 		if err != nil {
 			ret.Gnoland.Connected = false
 			errmsg := err.Error()
@@ -249,6 +291,10 @@ func handlerStatusJSON(logger *slog.Logger, app gotuna.App, cfg *Config) http.Ha
 
 func handlerRedirect(logger *slog.Logger, app gotuna.App, cfg *Config, to string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		to := to
+		if strings.HasPrefix(to, "/") {
+			to = basePath(r) + to
+		}
 		http.Redirect(w, r, to, http.StatusFound)
 		tmpl := app.NewTemplatingEngine()
 		tmpl.Set("To", to)
@@ -257,7 +303,7 @@ func handlerRedirect(logger *slog.Logger, app gotuna.App, cfg *Config, to string
 	})
 }
 
-func handlerRealmMain(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+func handlerRealmMain(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		rlmname := vars["rlmname"]
@@ -270,9 +316,9 @@ func handlerRealmMain(logger *slog.Logger, app gotuna.App, cfg *Config) http.Han
 			funcName := query.Get("__func")
 			qpath := "vm/qfuncs"
 			data := []byte(rlmpath)
-			res, err := makeRequest(logger, cfg, qpath, data)
+			res, err := chain.makeRequest(r.Context(), qpath, data)
 			if err != nil {
-				writeError(logger, w, fmt.Errorf("request failed: %w", err))
+				writeError(logger, app, cfg, w, r, fmt.Errorf("request failed: %w", err))
 				return
 			}
 			var fsigs vm.FunctionSignatures
@@ -286,25 +332,31 @@ func handlerRealmMain(logger *slog.Logger, app gotuna.App, cfg *Config) http.Han
 					param.Value = value
 				}
 			}
+			dirPath, err := pathOf(rlmpath)
+			if err != nil {
+				writeError(logger, app, cfg, w, r, err)
+				return
+			}
 			// Render template.
 			tmpl := app.NewTemplatingEngine()
 			tmpl.Set("FuncName", funcName)
 			tmpl.Set("RealmPath", rlmpath)
-			tmpl.Set("DirPath", pathOf(rlmpath))
+			tmpl.Set("DirPath", dirPath)
 			tmpl.Set("FunctionSignatures", fsigs)
 			tmpl.Set("Config", cfg)
+			tmpl.Set("Snapshot", res.Snapshot)
 			tmpl.Render(w, r, "funcs.html", "realm_help.html")
 		} else {
 			// Ensure realm exists. TODO optimize.
 			qpath := qFileStr
 			data := []byte(rlmpath)
-			_, err := makeRequest(logger, cfg, qpath, data)
+			_, err := chain.makeRequest(r.Context(), qpath, data)
 			if err != nil {
-				writeError(logger, w, errors.New("error querying realm package, remote="+cfg.RemoteAddr))
+				writeError(logger, app, cfg, w, r, fmt.Errorf("error querying realm package, remote=%s: %w", cfg.RemoteAddr, err))
 				return
 			}
 			// Render blank query path, /r/REALM:.
-			handleRealmRender(logger, app, cfg, w, r)
+			handleRealmRender(logger, app, cfg, chain, w, r)
 		}
 	})
 }
@@ -314,50 +366,56 @@ type pathLink struct {
 	Text string
 }
 
-func handlerRealmRender(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+func handlerRealmRender(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handleRealmRender(logger, app, cfg, w, r)
+		handleRealmRender(logger, app, cfg, chain, w, r)
 	})
 }
 
-func handleRealmRender(logger *slog.Logger, app gotuna.App, cfg *Config, w http.ResponseWriter, r *http.Request) {
+func handleRealmRender(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient, w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	rlmname := vars["rlmname"]
 	rlmpath := "gno.land/r/" + rlmname
 	querystr := vars["querystr"]
 	if r.URL.Path == "/r/"+rlmname+":" {
 		// Redirect to /r/REALM if querypath is empty.
-		http.Redirect(w, r, "/r/"+rlmname, http.StatusFound)
+		http.Redirect(w, r, basePath(r)+"/r/"+rlmname, http.StatusFound)
 		return
 	}
 	qpath := "vm/qrender"
 	data := []byte(fmt.Sprintf("%s:%s", rlmpath, querystr))
-	res, err := makeRequest(logger, cfg, qpath, data)
+	res, err := chain.makeRequest(r.Context(), qpath, data)
+	noRender := false
 	if err != nil {
-		// XXX hack
-		if strings.Contains(err.Error(), "Render not declared") {
-			res = &abci.ResponseQuery{}
+		if noRender = isNoRenderDecl(err); noRender {
+			res = &queryResult{ResponseQuery: &abci.ResponseQuery{}}
 			res.Data = []byte("realm package has no Render() function")
 		} else {
-			writeError(logger, w, err)
+			writeError(logger, app, cfg, w, r, err)
 			return
 		}
 	}
 
 	dirdata := []byte(rlmpath)
-	dirres, err := makeRequest(logger, cfg, qFileStr, dirdata)
+	dirres, err := chain.makeRequest(r.Context(), qFileStr, dirdata)
 	if err != nil {
-		writeError(logger, w, err)
+		writeError(logger, app, cfg, w, r, err)
 		return
 	}
 	hasReadme := bytes.Contains(append(dirres.Data, '\n'), []byte("README.md\n"))
 
+	html, err := renderMarkdown(string(res.Data), realmPolicy)
+	if err != nil {
+		writeError(logger, app, cfg, w, r, err)
+		return
+	}
+
 	// linkify querystr.
 	queryParts := strings.Split(querystr, "/")
 	pathLinks := []pathLink{}
 	for i, part := range queryParts {
 		pathLinks = append(pathLinks, pathLink{
-			URL:  "/r/" + rlmname + ":" + strings.Join(queryParts[:i+1], "/"),
+			URL:  basePath(r) + "/r/" + rlmname + ":" + strings.Join(queryParts[:i+1], "/"),
 			Text: part,
 		})
 	}
@@ -370,97 +428,91 @@ func handleRealmRender(logger *slog.Logger, app gotuna.App, cfg *Config, w http.
 	tmpl.Set("Query", querystr)
 	tmpl.Set("PathLinks", pathLinks)
 	tmpl.Set("Contents", string(res.Data))
+	tmpl.Set("HTML", prefixLinks(html, basePath(r)))
 	tmpl.Set("Config", cfg)
 	tmpl.Set("HasReadme", hasReadme)
+	tmpl.Set("Snapshot", res.Snapshot)
+	if noRender {
+		// the realm exists, and its files can be browsed
+		w.WriteHeader(http.StatusNotImplemented)
+	}
 	tmpl.Render(w, r, "realm_render.html", "funcs.html")
 }
 
-func handlerRealmFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+func handlerRealmFile(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		diruri := "gno.land/r/" + vars["rlmname"]
 		filename := vars["filename"]
-		renderPackageFile(logger, app, cfg, w, r, diruri, filename)
+		renderPackageFile(logger, app, cfg, chain, w, r, diruri, filename)
 	})
 }
 
-func handlerPackageFile(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
+func handlerPackageFile(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		pkgpath := "gno.land/p/" + vars["filepath"]
 		diruri, filename := SplitFilepath(pkgpath)
 		if filename == "" && diruri == pkgpath {
 			// redirect to diruri + "/"
-			http.Redirect(w, r, "/p/"+vars["filepath"]+"/", http.StatusFound)
+			http.Redirect(w, r, basePath(r)+"/p/"+vars["filepath"]+"/", http.StatusFound)
 			return
 		}
-		renderPackageFile(logger, app, cfg, w, r, diruri, filename)
+		renderPackageFile(logger, app, cfg, chain, w, r, diruri, filename)
 	})
 }
 
-func renderPackageFile(logger *slog.Logger, app gotuna.App, cfg *Config, w http.ResponseWriter, r *http.Request, diruri string, filename string) {
+func renderPackageFile(logger *slog.Logger, app gotuna.App, cfg *Config, chain *ChainClient, w http.ResponseWriter, r *http.Request, diruri string, filename string) {
 	if filename == "" {
 		// Request is for a folder.
 		qpath := qFileStr
 		data := []byte(diruri)
-		res, err := makeRequest(logger, cfg, qpath, data)
+		res, err := chain.makeRequest(r.Context(), qpath, data)
 		if err != nil {
-			writeError(logger, w, err)
+			writeError(logger, app, cfg, w, r, err)
 			return
 		}
 		files := strings.Split(string(res.Data), "\n")
+		dirPath, err := pathOf(diruri)
+		if err != nil {
+			writeError(logger, app, cfg, w, r, err)
+			return
+		}
 		// Render template.
 		tmpl := app.NewTemplatingEngine()
 		tmpl.Set("DirURI", diruri)
-		tmpl.Set("DirPath", pathOf(diruri))
+		tmpl.Set("DirPath", dirPath)
 		tmpl.Set("Files", files)
 		tmpl.Set("Config", cfg)
+		tmpl.Set("Snapshot", res.Snapshot)
 		tmpl.Render(w, r, "package_dir.html", "funcs.html")
 	} else {
 		// Request is for a file.
 		filepath := diruri + "/" + filename
 		qpath := qFileStr
 		data := []byte(filepath)
-		res, err := makeRequest(logger, cfg, qpath, data)
+		res, err := chain.makeRequest(r.Context(), qpath, data)
 		if err != nil {
-			writeError(logger, w, err)
+			writeError(logger, app, cfg, w, r, err)
+			return
+		}
+		dirPath, err := pathOf(diruri)
+		if err != nil {
+			writeError(logger, app, cfg, w, r, err)
 			return
 		}
 		// Render template.
 		tmpl := app.NewTemplatingEngine()
 		tmpl.Set("DirURI", diruri)
-		tmpl.Set("DirPath", pathOf(diruri))
+		tmpl.Set("DirPath", dirPath)
 		tmpl.Set("FileName", filename)
 		tmpl.Set("FileContents", string(res.Data))
 		tmpl.Set("Config", cfg)
+		tmpl.Set("Snapshot", res.Snapshot)
 		tmpl.Render(w, r, "package_file.html", "funcs.html")
 	}
 }
 
-func makeRequest(log *slog.Logger, cfg *Config, qpath string, data []byte) (res *abci.ResponseQuery, err error) {
-	opts2 := client.ABCIQueryOptions{
-		// Height: height, XXX
-		// Prove: false, XXX
-	}
-	remote := cfg.RemoteAddr
-	cli, err := client.NewHTTPClient(remote)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create HTTP client, %w", err)
-	}
-
-	qres, err := cli.ABCIQueryWithOptions(
-		qpath, data, opts2)
-	if err != nil {
-		log.Error("request error", "path", qpath, "error", err)
-		return nil, fmt.Errorf("unable to query path %q: %w", qpath, err)
-	}
-	if qres.Response.Error != nil {
-		log.Error("response error", "path", qpath, "log", qres.Response.Log)
-		return nil, qres.Response.Error
-	}
-	return &qres.Response, nil
-}
-
 func handlerStaticFile(logger *slog.Logger, app gotuna.App, cfg *Config, filesystem fs.FS) http.Handler {
 	fs := http.FS(filesystem)
 	fileapp := http.StripPrefix("/static", http.FileServer(fs))
@@ -472,12 +524,12 @@ func handlerStaticFile(logger *slog.Logger, app gotuna.App, cfg *Config, filesys
 		f, err := fs.Open(fpath)
 		if os.IsNotExist(err) || err != nil || f == nil {
 			logger.Debug("Not found: " + fpath)
-			handleNotFound(logger, app, cfg, fpath, w, r)
+			handleNotFound(logger, app, cfg, w, r)
 			return
 		}
 		stat, err := f.Stat()
 		if err != nil || stat.IsDir() {
-			handleNotFound(logger, app, cfg, fpath, w, r)
+			handleNotFound(logger, app, cfg, w, r)
 			return
 		}
 
@@ -489,6 +541,21 @@ func handlerStaticFile(logger *slog.Logger, app gotuna.App, cfg *Config, filesys
 	})
 }
 
+// handlerThemeFile serves the files of the theme name at /static/themes/<name>/,
+// as handlerStaticFile does at /static/.
+func handlerThemeFile(logger *slog.Logger, app gotuna.App, cfg *Config, name string, theme fs.FS) http.Handler {
+	files := handlerStaticFile(logger, app, cfg, theme)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/static/" + strings.TrimPrefix(r.URL.Path, "/static/themes/"+name+"/")
+		r2.URL.RawPath = ""
+		files.ServeHTTP(w, r2)
+	})
+}
+
 func handlerFavicon(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handler {
 	fs := http.FS(app.Static)
 
@@ -496,7 +563,7 @@ func handlerFavicon(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handl
 		fpath := "img/favicon.ico"
 		f, err := fs.Open(fpath)
 		if os.IsNotExist(err) {
-			handleNotFound(logger, app, cfg, fpath, w, r)
+			handleNotFound(logger, app, cfg, w, r)
 			return
 		}
 		w.Header().Set("Content-Type", "image/x-icon")
@@ -505,40 +572,18 @@ func handlerFavicon(logger *slog.Logger, app gotuna.App, cfg *Config) http.Handl
 	})
 }
 
-func handleNotFound(logger *slog.Logger, app gotuna.App, cfg *Config, path string, w http.ResponseWriter, r *http.Request) {
-	// decode path for non-ascii characters
-	decodedPath, err := url.PathUnescape(path)
-	if err != nil {
-		logger.Error("failed to decode path", err)
-		decodedPath = path
-	}
-	w.WriteHeader(http.StatusNotFound)
-	app.NewTemplatingEngine().
-		Set("title", "Not found").
-		Set("path", decodedPath).
-		Set("Config", cfg).
-		Render(w, r, "404.html", "funcs.html")
-}
-
-func writeError(logger *slog.Logger, w http.ResponseWriter, err error) {
-	if details := errors.Unwrap(err); details != nil {
-		logger.Error("handler", "error", err, "details", details)
-	} else {
-		logger.Error("handler", "error:", err)
-	}
-
-	// XXX: writeError should return an error page template.
-	w.WriteHeader(500)
-	w.Write([]byte(err.Error()))
+func handleNotFound(logger *slog.Logger, app gotuna.App, cfg *Config, w http.ResponseWriter, r *http.Request) {
+	renderError(logger, app, cfg, w, r, http.StatusNotFound, nil)
 }
 
-func pathOf(diruri string) string {
+// pathOf returns the url path of diruri, e.g. "gno.land/r/demo/boards" -> "/r/demo/boards".
+func pathOf(diruri string) (string, error) {
 	parts := strings.Split(diruri, "/")
 	if parts[0] == "gno.land" {
-		return "/" + strings.Join(parts[1:], "/")
+		return "/" + strings.Join(parts[1:], "/"), nil
 	}
 
-	panic(fmt.Sprintf("invalid dir-URI %q", diruri))
+	return "", fmt.Errorf("invalid dir-URI %q", diruri)
 }
 
 // Getter to defaultViewsFiles
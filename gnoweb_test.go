@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
@@ -46,7 +47,10 @@ func configWith(remoteAddr string) Config {
 	return *cfg
 }
 
-func TestRoutes(t *testing.T) {
+// testRoutes checks the routes of gnoweb, served from the node at remoteAddr:
+// the mock chain (see TestRoutes), or a gnoland node (see TestRoutesOnNode).
+func testRoutes(t *testing.T, remoteAddr string) {
+	t.Helper()
 	const (
 		ok       = http.StatusOK
 		found    = http.StatusFound
@@ -80,10 +84,12 @@ func TestRoutes(t *testing.T) {
 		{"/グノー", notFound, "/グノー"},
 		{"/⚛️", notFound, "/⚛️"},
 		{"/p/demo/flow/LICENSE", ok, "BSD 3-Clause"},
+		{"/r/demo/nothing", notFound, "/r/demo/nothing"},
+		{"/r/demo/nothing:page", notFound, "/r/demo/nothing:page"},
+		{"/p/demo/nothing/", notFound, "/p/demo/nothing/"},
+		{"/p/demo/flow/nothing.gno", notFound, "/p/demo/flow/nothing.gno"},
 	}
 
-	gnoland, remoteAddr := launchGnolandNode(t)
-	defer gnoland.Stop()
 	config := configWith(remoteAddr)
 	app := MakeGnowebAppWithOptions(log.NewTestingLogger(t), &config, Options{
 		Aliases:   miniAliases,
@@ -100,6 +106,21 @@ func TestRoutes(t *testing.T) {
 	}
 }
 
+func TestRoutes(t *testing.T) {
+	testRoutes(t, mockChain(t))
+}
+
+// TestRoutesOnNode runs testRoutes on an in-memory gnoland node. It is slow,
+// and only run if GNOASTEROID_NODE_TESTS is set.
+func TestRoutesOnNode(t *testing.T) {
+	if os.Getenv("GNOASTEROID_NODE_TESTS") == "" {
+		t.Skip("GNOASTEROID_NODE_TESTS is not set")
+	}
+	gnoland, remoteAddr := launchGnolandNode(t)
+	defer gnoland.Stop()
+	testRoutes(t, remoteAddr)
+}
+
 func TestAnalytics(t *testing.T) {
 	routes := []string{
 		// special realms
@@ -125,9 +146,7 @@ func TestAnalytics(t *testing.T) {
 		"/404-not-found",
 	}
 
-	gnoland, remoteAddr := launchGnolandNode(t)
-	defer gnoland.Stop()
-	cfg := configWith(remoteAddr)
+	cfg := configWith(mockChain(t))
 
 	t.Run("with", func(t *testing.T) {
 		for _, route := range routes {
@@ -162,3 +181,11 @@ func TestAnalytics(t *testing.T) {
 		}
 	})
 }
+
+func TestPathOf(t *testing.T) {
+	p, err := pathOf("gno.land/p/demo/avl")
+	assert.NoError(t, err)
+	assert.Equal(t, "/p/demo/avl", p)
+	_, err = pathOf("example.com/p/demo/avl")
+	assert.Error(t, err)
+}
@@ -0,0 +1,263 @@
+package gnAsteroid
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Unpublished files: dotfiles, the files matching the .asteroidignore at the
+// root of the asteroid (gitignore syntax), and pages having "draft: true" in
+// their front matter (unless Asteroid.Drafts).
+// The asteroid handler sees its FS through a publishedFS hiding them, so
+// that they are neither served, nor searched, nor listed, nor exported.
+
+// IgnoreFile is the name of the ignore file, at the root of an asteroid.
+const IgnoreFile = ".asteroidignore"
+
+// defaultIgnore is prepended to IgnoreFile: editor backup and swap files.
+const defaultIgnore = "*~\n*.swp\n*.swo\n\\#*#\n"
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool // "!pattern"
+	dirOnly bool // "pattern/"
+}
+
+// ignoreRules are the patterns of a gitignore file, the last matching one wins.
+type ignoreRules []ignorePattern
+
+// parseIgnoreRules parses content in gitignore syntax.
+func parseIgnoreRules(content string) ignoreRules {
+	var rules ignoreRules
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if p.negate = strings.HasPrefix(line, "!"); p.negate {
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\") // e.g. "\#file" or "\!file"
+		if p.dirOnly = strings.HasSuffix(line, "/"); p.dirOnly {
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// without a slash, the pattern matches at any depth
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		p.re = regexp.MustCompile("^" + globToRegexp(strings.TrimPrefix(line, "/")) + "$")
+		rules = append(rules, p)
+	}
+	return rules
+}
+
+// globToRegexp translates a gitignore glob ("*", "?", "[a-z]", "**") to a regexp.
+func globToRegexp(glob string) string {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
+
+// match tells whether the path p (e.g. "notes/draft.md") is ignored by itself,
+// not taking its parent directories into account.
+func (rules ignoreRules) match(p string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if (!rule.dirOnly || isDir) && rule.re.MatchString(p) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// ignored tells whether the path p is ignored, or is in an ignored directory.
+func (rules ignoreRules) ignored(p string, isDir bool) bool {
+	elems := strings.Split(p, "/")
+	for i := 1; i < len(elems); i++ {
+		if rules.match(strings.Join(elems[:i], "/"), true) {
+			return true
+		}
+	}
+	return rules.match(p, isDir)
+}
+
+// loadIgnoreRules reads the IgnoreFile of asteroid, if any.
+func loadIgnoreRules(logger *slog.Logger, asteroid fs.FS) ignoreRules {
+	content, e := fs.ReadFile(asteroid, IgnoreFile)
+	if e != nil && !errors.Is(e, fs.ErrNotExist) {
+		logger.Error("reading "+IgnoreFile, "error", e)
+	}
+	return parseIgnoreRules(defaultIgnore + string(content))
+}
+
+// publishedFS is an asteroid FS hiding its unpublished files.
+type publishedFS struct {
+	fsys   fs.FS
+	rules  ignoreRules
+	drafts bool            // if true, drafts are published
+	pages  map[string]bool // the pages found by newPublishedFS, true for drafts
+}
+
+// newPublishedFS hides the unpublished files of fsys, reading the front matter
+// of its pages once: the asteroid handler is rebuilt when they change.
+func newPublishedFS(logger *slog.Logger, fsys fs.FS, drafts bool) *publishedFS {
+	p := &publishedFS{fsys: fsys, rules: loadIgnoreRules(logger, fsys), drafts: drafts}
+	if drafts {
+		return p
+	}
+	p.pages = map[string]bool{}
+	e := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if name != "." && (isHidden(name) || p.rules.match(name, d.IsDir())) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && strings.HasSuffix(name, ".md") {
+			p.pages[name] = p.isDraft(name)
+		}
+		return nil
+	})
+	if e != nil {
+		logger.Error("looking for drafts", "error", e)
+	}
+	return p
+}
+
+// isDraft tells whether the page name has "draft: true" in its front matter.
+func (p *publishedFS) isDraft(name string) bool {
+	content, e := fs.ReadFile(p.fsys, name)
+	if e != nil {
+		return false // and will fail to open
+	}
+	_, fm, _ := ParseFrontMatter(string(content))
+	return fm.Bool("draft")
+}
+
+// hidden tells whether the file name (e.g. "notes/draft.md") is unpublished.
+func (p *publishedFS) hidden(name string, isDir bool) bool {
+	if name == "." {
+		return false
+	} else if isHidden(name) || p.rules.ignored(name, isDir) {
+		return true
+	} else if isDir || p.drafts || !strings.HasSuffix(name, ".md") {
+		return false
+	} else if draft, ok := p.pages[name]; ok {
+		return draft
+	}
+	return p.isDraft(name) // e.g. through a symlinked directory, not walked
+}
+
+func (p *publishedFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, e := p.fsys.Open(name)
+	if e != nil {
+		return nil, e
+	}
+	stat, e := f.Stat()
+	if e != nil {
+		f.Close()
+		return nil, e
+	}
+	if p.hidden(name, stat.IsDir()) {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if dir, ok := f.(fs.ReadDirFile); ok && stat.IsDir() {
+		return &publishedDir{ReadDirFile: dir, fs: p, name: name}, nil
+	}
+	return f, nil
+}
+
+func (p *publishedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	} else if p.hidden(name, true) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries, e := fs.ReadDir(p.fsys, name)
+	return p.visible(name, entries), e
+}
+
+// visible filters out the unpublished entries of the directory dir.
+func (p *publishedFS) visible(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	var visible []fs.DirEntry
+	for _, entry := range entries {
+		if !p.hidden(path.Join(dir, entry.Name()), entry.IsDir()) {
+			visible = append(visible, entry)
+		}
+	}
+	return visible
+}
+
+type publishedDir struct {
+	fs.ReadDirFile
+	fs   *publishedFS
+	name string
+}
+
+func (d *publishedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	for {
+		entries, e := d.ReadDirFile.ReadDir(n)
+		visible := d.fs.visible(d.name, entries)
+		if len(visible) > 0 || e != nil || n <= 0 {
+			return visible, e
+		}
+	}
+}
+
+// published returns a copy of a whose FS hides the unpublished files.
+func (a *Asteroid) published() *Asteroid {
+	if _, ok := a.FS.(*publishedFS); ok || a.FS == nil {
+		return a
+	}
+	b := *a
+	b.FS = newPublishedFS(a.logger(), a.FS, a.Drafts)
+	return &b
+}
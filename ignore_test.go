@@ -0,0 +1,149 @@
+package gnAsteroid
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules := parseIgnoreRules(defaultIgnore + `
+# notes
+notes.txt
+/private/
+build/
+*.log
+!keep.log
+docs/**/secret.md
+[Tt]odo.md
+\#hash.md
+`)
+	for _, tc := range []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"notes.txt", false, true},
+		{"sub/notes.txt", false, true},
+		{"private", true, true},
+		{"private/page.md", false, true},
+		{"sub/private/page.md", false, false},
+		{"build", true, true},
+		{"build", false, false}, // a file named build
+		{"sub/build/page.md", false, true},
+		{"error.log", false, true},
+		{"sub/keep.log", false, false},
+		{"docs/secret.md", false, true},
+		{"docs/a/b/secret.md", false, true},
+		{"secret.md", false, false},
+		{"todo.md", false, true},
+		{"Todo.md", false, true},
+		{"toDo.md", false, false},
+		{"#hash.md", false, true},
+		{"page.md~", false, true},
+		{"sub/.page.md.swp", false, true},
+		{"#page.md#", false, true},
+		{"page.md", false, false},
+	} {
+		assert.Equal(t, tc.ignored, rules.ignored(tc.path, tc.isDir), tc.path)
+	}
+}
+
+var unpublishedFS = fstest.MapFS{
+	"index.md":             {Data: []byte("# Home")},
+	".asteroidignore":      {Data: []byte("notes/\n*.bak\n")},
+	".TITLE":               {Data: []byte("Title")},
+	"post.md":              {Data: []byte("---\ntitle: Published post\ndate: 2024-01-01\ntags: [comet]\n---\nA comet.")},
+	"draft.md":             {Data: []byte("---\ntitle: Draft post\ndate: 2024-02-01\ntags: [comet]\ndraft: true\n---\nA comet, soon.")},
+	"toml-draft.md":        {Data: []byte("+++\ndraft = true\n+++\nA comet, later.")},
+	"notes/todo.md":        {Data: []byte("A comet to write about.")},
+	"blog/old.md.bak":      {Data: []byte("A comet.")},
+	"blog/page.md":         {Data: []byte("Blog.")},
+	"blog/page.md~":        {Data: []byte("A comet.")},
+	"blog/.page.md.swp":    {Data: []byte("A comet.")},
+	"img/comet.png":        {Data: []byte("png")},
+	"img/.hidden/x.png":    {Data: []byte("png")},
+	"sub/draft/index.md":   {Data: []byte("---\ndraft: yes\n---\n")},
+	"sub/draft/other.md":   {Data: []byte("Published, in a directory without index.")},
+	"sub/published/one.md": {Data: []byte("One.")},
+}
+
+func TestPublishedFS(t *testing.T) {
+	published := NewAsteroid(unpublishedFS, "Unpublished").published()
+	require.NoError(t, fstest.TestFS(published.FS,
+		"index.md", "post.md", "blog/page.md", "img/comet.png", "sub/draft/other.md", "sub/published/one.md"))
+	for _, hidden := range []string{".asteroidignore", ".TITLE", "draft.md", "toml-draft.md", "notes", "notes/todo.md",
+		"blog/old.md.bak", "blog/page.md~", "blog/.page.md.swp", "img/.hidden", "sub/draft/index.md"} {
+		_, e := published.FS.Open(hidden)
+		assert.Error(t, e, hidden)
+	}
+	drafts := NewAsteroid(unpublishedFS, "Drafts")
+	drafts.Drafts = true
+	_, e := drafts.published().FS.Open("draft.md")
+	assert.NoError(t, e)
+}
+
+// countingFS counts the files opened or read.
+type countingFS struct {
+	fstest.MapFS
+	opened map[string]int
+}
+
+func (c countingFS) Open(name string) (fs.File, error) {
+	c.opened[name]++
+	return c.MapFS.Open(name)
+}
+
+func (c countingFS) ReadFile(name string) ([]byte, error) {
+	c.opened[name]++
+	return c.MapFS.ReadFile(name)
+}
+
+// drafts are found once, not each time a page is opened or listed
+func TestPublishedFSReadsPagesOnce(t *testing.T) {
+	counting := countingFS{MapFS: unpublishedFS, opened: map[string]int{}}
+	published := NewAsteroid(counting, "Unpublished").published()
+	for i := 0; i < 3; i++ {
+		_, e := fs.ReadDir(published.FS, ".")
+		require.NoError(t, e)
+		_, e = fs.ReadFile(published.FS, "post.md")
+		require.NoError(t, e)
+	}
+	assert.Equal(t, 1, counting.opened["draft.md"])
+	assert.Equal(t, 4, counting.opened["post.md"], "read once for drafts, then for each ReadFile")
+}
+
+func TestUnpublishedAreNotServed(t *testing.T) {
+	asteroid := NewAsteroid(unpublishedFS, "Unpublished")
+	asteroid.DirIndex = true
+	handler := asteroid.Handler()
+	for _, route := range []string{"/.asteroidignore", "/.TITLE", "/draft.md", "/notes/todo.md", "/notes/", "/blog/old.md.bak", "/blog/page.md~"} {
+		assert.Equal(t, http.StatusNotFound, serveRoute(t, handler, route, nil).Code, route)
+	}
+	assert.Equal(t, http.StatusOK, serveRoute(t, handler, "/post.md", nil).Code)
+	search := serveRoute(t, handler, "/search.json?q=comet", nil).Body.String()
+	assert.Contains(t, search, "Published post")
+	assert.NotContains(t, search, "Draft post")
+	assert.NotContains(t, search, "todo")
+	assert.NotContains(t, serveRoute(t, handler, "/feed.json", nil).Body.String(), "Draft post")
+	assert.NotContains(t, serveRoute(t, handler, "/tags/comet/", nil).Body.String(), "Draft post")
+	blog := serveRoute(t, handler, "/blog/", nil).Body.String()
+	assert.Contains(t, blog, "page.md")
+	assert.NotContains(t, blog, "old.md.bak")
+	// a directory whose index is a draft gets a generated one
+	assert.Contains(t, serveRoute(t, handler, "/sub/draft/", nil).Body.String(), "other.md")
+
+	asteroid.Drafts = true
+	handler = asteroid.Handler()
+	assert.Equal(t, http.StatusOK, serveRoute(t, handler, "/draft.md", nil).Code)
+	assert.Contains(t, serveRoute(t, handler, "/search.json?q=comet", nil).Body.String(), "Draft post")
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, handler, "/notes/todo.md", nil).Code)
+
+	// loadPages, on an unpublished FS, still lists everything but dotfiles
+	assert.Len(t, loadPages(slog.Default(), unpublishedFS), 9)
+}
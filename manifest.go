@@ -0,0 +1,313 @@
+package gnAsteroid
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"text/template/parse"
+
+	toml "github.com/pelletier/go-toml"
+	"github.com/yalue/merged_fs"
+)
+
+// ManifestFile is the optional manifest of a theme, at its root:
+//
+//	name = "sidebar"
+//	version = "1.0.0"
+//	author = "Bob"
+//	license = "MIT"
+//	parent = "cloudy"
+//	color_schemes = ["light", "dark"]
+//	blocks = ["sidebar"]
+const ManifestFile = "theme.toml"
+
+// colorSchemes are the valid Manifest.ColorSchemes, as toggled by header_buttons.
+var colorSchemes = []string{"light", "dark"}
+
+// Manifest describes a theme, see ManifestFile.
+type Manifest struct {
+	Name         string   `toml:"name"`
+	Version      string   `toml:"version"`
+	Author       string   `toml:"author"`
+	License      string   `toml:"license"`
+	Parent       string   `toml:"parent"`        // installed theme whose files are used when missing from this one, e.g. "cloudy"
+	ColorSchemes []string `toml:"color_schemes"` // "light" and/or "dark", both if empty. with one, the dark mode toggle is hidden
+	Blocks       []string `toml:"blocks"`        // templates the views of the theme must define, e.g. "sidebar", see CheckTheme
+}
+
+// ReadManifest reads the ManifestFile of theme, nil if it has none.
+func ReadManifest(theme fs.FS) (*Manifest, error) {
+	content, e := fs.ReadFile(theme, ManifestFile)
+	if errors.Is(e, fs.ErrNotExist) {
+		return nil, nil
+	} else if e != nil {
+		return nil, e
+	}
+	m := &Manifest{}
+	if e := toml.Unmarshal(content, m); e != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestFile, e)
+	}
+	return m, nil
+}
+
+// InheritTheme returns theme, with the files of its parent (see Manifest.Parent),
+// and of the parent of its parent..., taken from installed, e.g. the themes
+// of the same directory.
+func InheritTheme(theme fs.FS, installed map[string]fs.FS) (fs.FS, error) {
+	seen := map[string]bool{}
+	inherited := theme
+	for {
+		m, e := ReadManifest(theme)
+		if e != nil {
+			return nil, e
+		} else if m == nil || m.Parent == "" {
+			return inherited, nil
+		} else if seen[m.Parent] {
+			return nil, fmt.Errorf("%s: theme %q inherits from itself", ManifestFile, m.Parent)
+		}
+		seen[m.Parent] = true
+		parent, ok := installed[m.Parent]
+		if !ok {
+			return nil, fmt.Errorf("%s: parent theme %q is not installed", ManifestFile, m.Parent)
+		}
+		inherited = merged_fs.NewMergedFS(inherited, parent)
+		theme = parent
+	}
+}
+
+// InheritThemes applies InheritTheme to each of themes.
+func InheritThemes(themes map[string]fs.FS) (map[string]fs.FS, error) {
+	inherited := map[string]fs.FS{}
+	for name, theme := range themes {
+		theme, e := InheritTheme(theme, themes)
+		if e != nil {
+			return nil, fmt.Errorf("theme %s: %w", name, e)
+		}
+		inherited[name] = theme
+	}
+	return inherited, nil
+}
+
+// CheckTheme validates theme (once inherited, see InheritTheme): its ManifestFile,
+// and its views (see ThemeViewsDir) against the views they override:
+//   - they must parse,
+//   - they must define the templates defined by the views they override,
+//     e.g. "header_buttons" for funcs.html,
+//   - the templates used by the pages must be defined, by the page or by funcs.html,
+//   - the Manifest.Blocks must be defined by the views of the theme.
+//
+// It returns the problems found, if any.
+func CheckTheme(theme fs.FS) []error {
+	var problems []error
+	m, e := ReadManifest(theme)
+	if e != nil {
+		problems = append(problems, e)
+	} else if m != nil {
+		problems = append(problems, m.check()...)
+	}
+	if !hasViews(theme) {
+		if m != nil && len(m.Blocks) > 0 {
+			problems = append(problems, fmt.Errorf("%s: blocks %v, but the theme has no %s/", ManifestFile, m.Blocks, ThemeViewsDir))
+		}
+		return problems
+	}
+	views, e := (&Asteroid{}).views(theme)
+	if e != nil {
+		return append(problems, e)
+	}
+	baseViews, e := (&Asteroid{}).views(nil)
+	if e != nil {
+		return append(problems, e)
+	}
+	themeViews, e := fs.Sub(theme, ThemeViewsDir)
+	if e != nil {
+		return append(problems, e)
+	}
+	overridden, e := fs.Glob(themeViews, "*.html")
+	if e != nil {
+		return append(problems, e)
+	}
+	defined := map[string]bool{} // by the views of the theme
+	for _, name := range overridden {
+		file := path.Join(ThemeViewsDir, name)
+		base, e := parseView(baseViews, name)
+		if errors.Is(e, fs.ErrNotExist) {
+			problems = append(problems, fmt.Errorf("%s: overrides no view, it is never used", file))
+			continue
+		} else if e != nil {
+			return append(problems, e)
+		}
+		view, e := parseView(views, name)
+		if e != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", file, e))
+			continue
+		}
+		for _, define := range missing(sorted(base.defines), view.defines) {
+			problems = append(problems, fmt.Errorf("%s: template %q is not defined, as in the view it overrides", file, define))
+		}
+		for define := range view.defines {
+			defined[define] = true
+		}
+	}
+	if m != nil {
+		for _, block := range missing(m.Blocks, defined) {
+			problems = append(problems, fmt.Errorf("%s: block %q is not defined by the views of the theme", ManifestFile, block))
+		}
+	}
+
+	// pages are rendered with funcs.html
+	funcs, e := parseView(views, "funcs.html")
+	if e != nil {
+		return problems // reported above
+	}
+	pages, e := fs.Glob(baseViews, "*.html") // the other views are never used
+	if e != nil {
+		return append(problems, e)
+	}
+	for _, name := range pages {
+		if name == "funcs.html" {
+			continue
+		}
+		page, e := parseView(views, name)
+		if e != nil {
+			continue // reported above, if the theme overrides it
+		}
+		uses := map[string][]string{}
+		for _, v := range []*view{page, funcs} {
+			for define, used := range v.uses {
+				uses[define] = append(uses[define], used...)
+			}
+		}
+		for _, template := range undefined(uses, "app") {
+			problems = append(problems, fmt.Errorf("%s: template %q is used, but not defined", name, template))
+		}
+	}
+	return problems
+}
+
+// check validates the fields of m.
+func (m *Manifest) check() []error {
+	var problems []error
+	if m.Name != "" && !reThemeName.MatchString(m.Name) {
+		problems = append(problems, fmt.Errorf("%s: invalid name %q, expected %s", ManifestFile, m.Name, reThemeName))
+	}
+	for _, scheme := range missing(m.ColorSchemes, set(colorSchemes)) {
+		problems = append(problems, fmt.Errorf("%s: invalid color scheme %q, expected one of %v", ManifestFile, scheme, colorSchemes))
+	}
+	return problems
+}
+
+// view is the templates a view defines, and the templates each of them uses.
+type view struct {
+	defines map[string]bool
+	uses    map[string][]string
+}
+
+// parseView parses the view name of views, without checking the functions it calls.
+func parseView(views fs.FS, name string) (*view, error) {
+	content, e := fs.ReadFile(views, name)
+	if e != nil {
+		return nil, e
+	}
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, e := tree.Parse(string(content), "{{", "}}", trees); e != nil {
+		return nil, e
+	}
+	v := &view{defines: map[string]bool{}, uses: map[string][]string{}}
+	for define, t := range trees {
+		if define != name {
+			v.defines[define] = true
+		}
+		v.uses[define] = templatesUsed(t.Root)
+	}
+	return v, nil
+}
+
+// undefined returns the templates used, directly or not, by template, which are not defined in uses.
+func undefined(uses map[string][]string, template string) []string {
+	var names []string
+	seen := map[string]bool{template: true}
+	for todo := []string{template}; len(todo) > 0; todo = todo[1:] {
+		used, ok := uses[todo[0]]
+		if !ok {
+			names = append(names, todo[0])
+		}
+		for _, name := range used {
+			if !seen[name] {
+				seen[name] = true
+				todo = append(todo, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templatesUsed returns the names of the templates used under node, by {{ template }}.
+func templatesUsed(node parse.Node) []string {
+	var used []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			used = append(used, templatesUsed(child)...)
+		}
+	case *parse.TemplateNode:
+		used = append(used, n.Name)
+	case *parse.IfNode:
+		used = append(templatesUsed(n.List), templatesUsed(n.ElseList)...)
+	case *parse.RangeNode:
+		used = append(templatesUsed(n.List), templatesUsed(n.ElseList)...)
+	case *parse.WithNode:
+		used = append(templatesUsed(n.List), templatesUsed(n.ElseList)...)
+	}
+	return used
+}
+
+// missing returns the names not in defined, without duplicates.
+func missing(names []string, defined map[string]bool) []string {
+	var m []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !defined[name] && !seen[name] {
+			m = append(m, name)
+		}
+		seen[name] = true
+	}
+	return m
+}
+
+func sorted(names map[string]bool) []string {
+	var s []string
+	for name := range names {
+		s = append(s, name)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func set(names []string) map[string]bool {
+	s := map[string]bool{}
+	for _, name := range names {
+		s[name] = true
+	}
+	return s
+}
+
+// colorSchemes returns the Manifest.ColorSchemes of theme, if any.
+func (a *Asteroid) colorSchemes(theme fs.FS) []string {
+	m, e := ReadManifest(theme)
+	if e != nil {
+		a.logger().Warn("theme manifest ignored", "error", e)
+		return nil
+	} else if m == nil {
+		return nil
+	}
+	return m.ColorSchemes
+}
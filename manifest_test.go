@@ -0,0 +1,112 @@
+package gnAsteroid
+
+import (
+	"io/fs"
+	"net/http"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadManifest(t *testing.T) {
+	m, e := ReadManifest(fstest.MapFS{})
+	require.NoError(t, e)
+	assert.Nil(t, m)
+
+	m, e = ReadManifest(os.DirFS("themes/raw.theme"))
+	require.NoError(t, e)
+	assert.Equal(t, &Manifest{Name: "raw", ColorSchemes: []string{"light"}}, m)
+
+	_, e = ReadManifest(fstest.MapFS{ManifestFile: {Data: []byte("name = ")}})
+	assert.Error(t, e)
+}
+
+func TestInheritTheme(t *testing.T) {
+	installed := map[string]fs.FS{
+		"base": fstest.MapFS{
+			"css/common.css": {Data: []byte("base css")},
+			"img/logo.svg":   {Data: []byte("base logo")},
+		},
+		"child": fstest.MapFS{
+			ManifestFile:     {Data: []byte(`parent = "base"`)},
+			"css/common.css": {Data: []byte("child css")},
+		},
+		"grandchild": fstest.MapFS{ManifestFile: {Data: []byte(`parent = "child"`)}},
+		"orphan":     fstest.MapFS{ManifestFile: {Data: []byte(`parent = "nope"`)}},
+		"loop":       fstest.MapFS{ManifestFile: {Data: []byte(`parent = "loop"`)}},
+	}
+	theme, e := InheritTheme(installed["grandchild"], installed)
+	require.NoError(t, e)
+	content, e := fs.ReadFile(theme, "css/common.css")
+	require.NoError(t, e)
+	assert.Equal(t, "child css", string(content))
+	content, e = fs.ReadFile(theme, "img/logo.svg")
+	require.NoError(t, e)
+	assert.Equal(t, "base logo", string(content))
+
+	_, e = InheritTheme(installed["orphan"], installed)
+	assert.ErrorContains(t, e, "not installed")
+	_, e = InheritTheme(installed["loop"], installed)
+	assert.ErrorContains(t, e, "inherits from itself")
+	_, e = InheritThemes(installed)
+	assert.Error(t, e)
+}
+
+func TestCheckTheme(t *testing.T) {
+	for _, name := range []string{"bubbly", "cloudy", "raw", "readable"} {
+		assert.Empty(t, CheckTheme(os.DirFS("themes/"+name+".theme")), name)
+	}
+	assert.Empty(t, CheckTheme(os.DirFS(".")), "views/ overriding themselves")
+
+	funcs, e := os.ReadFile("views/funcs.html")
+	require.NoError(t, e)
+	theme := fstest.MapFS{
+		ManifestFile:          {Data: []byte("name = \"Sidebar\"\ncolor_schemes = [\"light\", \"sepia\"]\nblocks = [\"sidebar\"]\n")},
+		"views/funcs.html":    {Data: append(funcs, `{{ define "sidebar" }}{{ template "toc" . }}{{ end }}`...)},
+		"views/404.html":      {Data: []byte(`{{ define "app" }}{{ template "html_head" . }}{{ end }}`)},
+		"views/500.html":      {Data: []byte(`{{ define "app" }}{{ if }}{{ end }}`)},
+		"views/sidebar.html":  {Data: []byte(`{{ define "toc" }}{{ end }}`)},
+		"views/generic.html":  {Data: []byte(`{{ define "app" }}{{ template "sidebar" . }}{{ end }}`)},
+		"views/redirect.html": {Data: []byte(`{{ define "app" }}{{ template "header" . }}{{ end }}`)},
+	}
+	var problems []string
+	for _, problem := range CheckTheme(theme) {
+		problems = append(problems, problem.Error())
+	}
+	assert.ElementsMatch(t, []string{
+		`theme.toml: invalid name "Sidebar", expected ^[a-z0-9_-]+$`,
+		`theme.toml: invalid color scheme "sepia", expected one of [light dark]`,
+		`views/404.html: template "error_message" is not defined, as in the view it overrides`,
+		`views/500.html: template: 500.html:1: missing value for if`,
+		`views/sidebar.html: overrides no view, it is never used`,
+		`generic.html: template "toc" is used, but not defined`,
+		`redirect.html: template "header" is used, but not defined`,
+	}, problems)
+
+	problems = nil
+	for _, problem := range CheckTheme(fstest.MapFS{ManifestFile: {Data: []byte(`blocks = ["sidebar"]`)}}) {
+		problems = append(problems, problem.Error())
+	}
+	assert.Equal(t, []string{`theme.toml: blocks [sidebar], but the theme has no views/`}, problems)
+}
+
+func TestColorSchemes(t *testing.T) {
+	a := NewAsteroid(fstest.MapFS{"index.md": {Data: []byte("# Home")}}, "Juno")
+	a.ThemeFS = fstest.MapFS{ManifestFile: {Data: []byte(`color_schemes = ["dark"]`)}}
+	a.Themes = map[string]fs.FS{"raw": fstest.MapFS{}}
+	handler := a.Handler()
+	get := func(route string) string {
+		response := serveRoute(t, handler, route, nil)
+		require.Equal(t, http.StatusOK, response.Code, route)
+		return response.Body.String()
+	}
+	body := get("/")
+	assert.Contains(t, body, `<meta name="color-scheme" content="dark" />`)
+	assert.NotContains(t, body, `id="theme-toggle"`, "nothing to toggle")
+	body = get("/?theme=raw")
+	assert.NotContains(t, body, `name="color-scheme"`)
+	assert.Contains(t, body, `id="theme-toggle"`)
+}
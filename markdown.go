@@ -0,0 +1,58 @@
+package gnAsteroid
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Markdown is rendered to HTML on the server, so pages can be read
+// without javascript, by crawlers, and quickly on phones.
+// Rendering on the client (marked.js + DOMPurify) remains possible with
+// Config.ClientSideMarkdown.
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM), // tables, strikethrough, task lists, autolinks
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(html.WithUnsafe()), // raw HTML is kept, then sanitized by a policy
+)
+
+var (
+	// realmPolicy sanitizes HTML rendered from markdown we don't own, e.g. realms' Render()
+	realmPolicy = newMarkdownPolicy()
+	// asteroidPolicy sanitizes HTML rendered from asteroid files, written by the asteroid author.
+	// Unlike realmPolicy it allows <style>, style attributes and followed links.
+	asteroidPolicy = newMarkdownPolicy().
+			AllowUnsafe(true).
+			AllowElements("style").
+			AllowAttrs("type").OnElements("style").
+			AllowAttrs("style").Globally().
+			RequireNoFollowOnLinks(false)
+)
+
+func newMarkdownPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// code blocks, for syntax highlighting
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	// task lists
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	// images, as often laid out in asteroids
+	p.AllowAttrs("hspace", "vspace").Matching(bluemonday.Integer).OnElements("img")
+	return p
+}
+
+// renderMarkdown renders markdown to HTML, sanitized with policy.
+func renderMarkdown(md string, policy *bluemonday.Policy) (template.HTML, error) {
+	var buf bytes.Buffer
+	if e := markdown.Convert([]byte(md), &buf); e != nil {
+		return "", e
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
@@ -0,0 +1,66 @@
+package gnAsteroid
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name, md, contains, excludes string
+	}{
+		{"table", "| a | b |\n|---|---|\n| 1 | 2 |", "<td>2</td>", "|"},
+		{"strikethrough", "~~gone~~", "<del>gone</del>", "~~"},
+		{"task list", "- [x] done\n- [ ] todo", `<input checked="" disabled="" type="checkbox"`, "[x]"},
+		{"autolink", "see https://gno.land", `<a href="https://gno.land"`, ""},
+		{"heading id", "# Hello world", `<h1 id="hello-world">`, ""},
+		{"code class", "```go\nfunc main() {}\n```", `<code class="language-go">`, ""},
+		{"script", "<script>alert(1)</script>hi", "hi", "<script"},
+		{"onclick", `<a href="/x" onclick="alert(1)">x</a>`, `<a href="/x"`, "onclick"},
+		{"javascript url", "[x](javascript:alert(1))", "x", "javascript:"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			html, e := renderMarkdown(tc.md, realmPolicy)
+			assert.NoError(t, e)
+			assert.Contains(t, string(html), tc.contains)
+			if tc.excludes != "" {
+				assert.NotContains(t, string(html), tc.excludes)
+			}
+		})
+	}
+
+	// asteroid authors may style their pages, realms may not
+	styled := "<style>img { width: 1px; }</style>\n\n<img src=a.svg style=\"padding: 1px\" hspace=10 />"
+	html, _ := renderMarkdown(styled, asteroidPolicy)
+	assert.Contains(t, string(html), "<style>")
+	assert.Contains(t, string(html), `style="padding: 1px"`)
+	assert.Contains(t, string(html), `hspace="10"`)
+	html, _ = renderMarkdown(styled, realmPolicy)
+	assert.NotContains(t, string(html), "<style>")
+	assert.NotContains(t, string(html), "padding")
+}
+
+func TestServerSideMarkdown(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{
+		"index.md": {Data: []byte("# Home\n\n| a | b |\n|---|---|\n| 1 | 2 |")},
+	}, "Table")
+	for _, tc := range []struct {
+		clientSide bool
+		contains   string
+		excludes   string
+	}{
+		{false, "<td>2</td>", `id="source"`},
+		{true, `id="source"`, "<td>2</td>"},
+	} {
+		cfg := NewDefaultConfig()
+		cfg.ClientSideMarkdown = tc.clientSide
+		asteroid.Config = cfg
+		response := serveRoute(t, asteroid.Handler(), "/", nil)
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Body.String(), tc.contains)
+		assert.NotContains(t, response.Body.String(), tc.excludes)
+	}
+}
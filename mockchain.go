@@ -0,0 +1,175 @@
+package gnAsteroid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gnolang/gno/gno.land/pkg/sdk/vm"
+	"github.com/gnolang/gno/tm2/pkg/amino"
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+	ctypes "github.com/gnolang/gno/tm2/pkg/bft/rpc/core/types"
+	rpctypes "github.com/gnolang/gno/tm2/pkg/bft/rpc/lib/types"
+)
+
+// MockChain is a fake gnoland node, for tests and for working on themes and
+// content offline: Config.RemoteAddr can point to it (e.g. with httptest.NewServer).
+// It answers the ABCI queries done by gnoweb from a fixtures directory,
+// in which packages are directories named by their path, without the domain:
+//
+//	r/demo/users/users.gno      package files, listed and served by vm/qfile
+//	r/demo/users/.funcs.json    vm/qfuncs, i.e. JSON of vm.FunctionSignatures, optional
+//	r/demo/users/.render/       vm/qrender. Without it, the realm has no Render()
+//	r/demo/users/.render/index.md       Render("")
+//	r/demo/users/.render/admin/moul.md  Render("admin/moul")
+type MockChain struct {
+	fixtures fs.FS
+	logger   *slog.Logger
+}
+
+// mockHeight is the height of every answer of a MockChain.
+const mockHeight = 1
+
+func NewMockChain(fixtures fs.FS, logger *slog.Logger) *MockChain {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &MockChain{fixtures: fixtures, logger: logger}
+}
+
+// ServeHTTP answers "abci_query" JSON-RPC requests, as sent by ChainClient.
+func (m *MockChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request rpctypes.RPCRequest
+	if e := json.NewDecoder(r.Body).Decode(&request); e != nil {
+		m.reply(w, rpctypes.RPCParseError(request.ID, e))
+		return
+	}
+	if request.Method != "abci_query" {
+		m.reply(w, rpctypes.RPCMethodNotFoundError(request.ID))
+		return
+	}
+	var params struct {
+		Path string `json:"path"`
+		Data []byte `json:"data"`
+	}
+	if e := amino.UnmarshalJSON(request.Params, &params); e != nil {
+		m.reply(w, rpctypes.RPCInvalidParamsError(request.ID, e))
+		return
+	}
+	m.reply(w, rpctypes.NewRPCSuccessResponse(request.ID, ctypes.ResultABCIQuery{
+		Response: m.query(params.Path, params.Data),
+	}))
+}
+
+func (m *MockChain) reply(w http.ResponseWriter, response rpctypes.RPCResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if e := json.NewEncoder(w).Encode(response); e != nil {
+		m.logger.Error("mock chain", "error", e)
+	}
+}
+
+func (m *MockChain) query(qpath string, data []byte) (res abci.ResponseQuery) {
+	m.logger.Debug("mock chain", "path", qpath, "data", string(data))
+	res.Height = mockHeight
+	var result string
+	var err error
+	switch qpath {
+	case ".app/version":
+		res.Value = []byte("mock")
+		return res
+	case "vm/qrender":
+		pkgPath, renderPath, _ := strings.Cut(string(data), ":")
+		result, err = m.render(pkgPath, renderPath)
+	case "vm/qfuncs":
+		result, err = m.funcs(string(data))
+	case qFileStr:
+		result, err = m.file(string(data))
+	default:
+		err = fmt.Errorf("unknown query path %s", qpath)
+	}
+	if err != nil {
+		var abciErr abci.Error
+		if !errors.As(err, &abciErr) {
+			abciErr = abci.StringError(err.Error())
+		}
+		res.Error = abciErr
+		res.Log = err.Error()
+		return res
+	}
+	res.Data = []byte(result)
+	return res
+}
+
+// fixture returns the path in fixtures of a package path (or package file path),
+// e.g. "gno.land/r/demo/users" -> "r/demo/users".
+// Files starting with a dot are not part of packages.
+func (m *MockChain) fixture(pkgPath string) (string, error) {
+	_, p, _ := strings.Cut(pkgPath, "/")
+	if !fs.ValidPath(p) || strings.HasPrefix(p, ".") || strings.Contains(p, "/.") {
+		return "", fmt.Errorf("invalid path %q", pkgPath)
+	}
+	if _, e := fs.Stat(m.fixtures, p); e != nil {
+		return "", fmt.Errorf("%q is not available", pkgPath)
+	}
+	return p, nil
+}
+
+func (m *MockChain) render(pkgPath, renderPath string) (string, error) {
+	p, e := m.fixture(pkgPath)
+	if e != nil {
+		return "", e
+	}
+	if _, e := fs.Stat(m.fixtures, path.Join(p, ".render")); e != nil {
+		return "", vm.NoRenderDeclError{}
+	}
+	name := "index"
+	if renderPath != "" {
+		name = renderPath
+	}
+	name = path.Join(p, ".render", name+".md")
+	if !fs.ValidPath(name) || !strings.HasPrefix(name, p+"/.render/") {
+		return "", fmt.Errorf("invalid render path %q", renderPath)
+	}
+	content, e := fs.ReadFile(m.fixtures, name)
+	if e != nil {
+		return "", fmt.Errorf("no fixture for %s Render(%q)", pkgPath, renderPath)
+	}
+	return string(content), nil
+}
+
+func (m *MockChain) funcs(pkgPath string) (string, error) {
+	p, e := m.fixture(pkgPath)
+	if e != nil {
+		return "", e
+	}
+	content, e := fs.ReadFile(m.fixtures, path.Join(p, ".funcs.json"))
+	if errors.Is(e, fs.ErrNotExist) {
+		return "[]", nil
+	}
+	return string(content), e
+}
+
+// file returns the content of a package file, or the file names of a package.
+func (m *MockChain) file(filePath string) (string, error) {
+	p, e := m.fixture(filePath)
+	if e != nil {
+		return "", e
+	}
+	entries, e := fs.ReadDir(m.fixtures, p)
+	if e != nil {
+		content, e := fs.ReadFile(m.fixtures, p)
+		return string(content), e
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return strings.Join(names, "\n"), nil
+}
@@ -0,0 +1,77 @@
+package gnAsteroid
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockChain serves testdata/chain as a gnoland node, and returns its address.
+func mockChain(t *testing.T) string {
+	node := httptest.NewServer(NewMockChain(os.DirFS("testdata/chain"), nil))
+	t.Cleanup(node.Close)
+	return node.URL
+}
+
+// serveRoute serves a GET request of route (e.g. "/feed.xml", or "https://example.com/feed.xml")
+// with handler (e.g. asteroid.Handler()), having header if not nil.
+func serveRoute(t *testing.T, handler http.Handler, route string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, route, nil)
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func TestMockChain(t *testing.T) {
+	cfg := configWith(mockChain(t))
+	app := MakeGnowebAppWithOptions(slog.Default(), &cfg, Options{})
+	for _, tc := range []struct {
+		route     string
+		status    int
+		substring string
+	}{
+		{"/r/demo/users", http.StatusOK, `<a href="/r/demo/users:moul"`},
+		{"/r/demo/users:moul", http.StatusOK, "g1u7y667z64x2h7vc6fmpcprgey4ck233jaww9zq"},
+		{"/r/demo/users:nobody", http.StatusInternalServerError, "Internal Server Error"},
+		{"/r/demo/users?help", http.StatusOK, "Register"},
+		{"/r/demo/users/", http.StatusOK, "users.gno"},
+		{"/r/demo/users/", http.StatusOK, "README.md"},
+		{"/r/demo/users/users.gno", http.StatusOK, "// State"},
+		{"/r/demo/norender", http.StatusNotImplemented, "realm package has no Render() function"},
+		{"/p/demo/avl/", http.StatusOK, "node.gno"},
+		{"/p/demo/avl/avl.gno", http.StatusOK, "immutable AVL tree"},
+		{"/p/demo/nothing/", http.StatusNotFound, "Not Found"},
+		{"/status.json", http.StatusOK, `"version": "mock"`},
+	} {
+		response := serveRoute(t, app.Router, tc.route, nil)
+		assert.Equal(t, tc.status, response.Code, tc.route)
+		assert.Contains(t, response.Body.String(), tc.substring, tc.route)
+	}
+}
+
+// fixtures which aren't package files can't be queried
+func TestMockChainPaths(t *testing.T) {
+	cfg := configWith(mockChain(t))
+	chain := NewChainClient(slog.Default(), &cfg)
+	for _, tc := range []struct {
+		qpath, data, err string
+	}{
+		{"vm/qrender", "gno.land/r/demo/users:../../norender/norender.gno", "invalid render path"},
+		{"vm/qfile", "gno.land/r/demo/users/.funcs.json", "invalid path"},
+		{"vm/qfile", "gno.land/r/demo/users/.render/moul.md", "invalid path"},
+		{"vm/qfile", "gno.land/../chain/r/demo/users", "invalid path"},
+		{"vm/qeval", "gno.land/r/demo/users.Render(\"\")", "unknown query path"},
+	} {
+		_, e := chain.makeRequest(context.Background(), tc.qpath, []byte(tc.data))
+		assert.ErrorContains(t, e, tc.err, tc.data)
+	}
+}
@@ -0,0 +1,82 @@
+package gnAsteroid
+
+import (
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+)
+
+// page is a markdown file of an asteroid, as listed by search, feeds, etc.
+type page struct {
+	File     string      // e.g. "blog/post.md"
+	URL      string      // url path serving it, e.g. "/blog/post.md", or "/blog/" for blog/index.md
+	Meta     FrontMatter // see ParseFrontMatter
+	Markdown string      // without front matter
+}
+
+// loadPages reads the markdown pages of asteroid, dotfiles excepted.
+func loadPages(logger *slog.Logger, asteroid fs.FS) []page {
+	var pages []page
+	e := fs.WalkDir(asteroid, ".", func(p string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if p != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".md") {
+			return nil
+		}
+		content, e := fs.ReadFile(asteroid, p)
+		if e != nil {
+			logger.Error("reading page", "file", p, "error", e)
+			return nil
+		}
+		pureMarkdown, fm := frontMatterOf(logger, p, string(content))
+		pages = append(pages, page{
+			File:     p,
+			URL:      pageURL(asteroid, p),
+			Meta:     fm,
+			Markdown: pureMarkdown,
+		})
+		return nil
+	})
+	if e != nil {
+		logger.Error("reading pages", "error", e)
+	}
+	return pages
+}
+
+// pageURL returns the url path serving the markdown file p:
+// its directory for an index.md (or a README.md without index.md).
+func pageURL(asteroid fs.FS, p string) string {
+	dir, file := path.Split(p)
+	switch file {
+	case "index.md":
+		return "/" + dir
+	case "README.md":
+		if _, e := fs.Stat(asteroid, dir+"index.md"); e != nil {
+			return "/" + dir
+		}
+	}
+	return "/" + p
+}
+
+// title returns the front matter title, or else the file name without .md,
+// like HandleNotFoundAsFile.
+func (p page) title() string {
+	if title := p.Meta.String("title"); title != "" {
+		return title
+	}
+	return strings.TrimSuffix(p.File, ".md")
+}
+
+// date returns the front matter date, if any.
+func (p page) date() (time.Time, bool) {
+	return p.Meta.Time("date")
+}
@@ -0,0 +1,62 @@
+package gnAsteroid
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// An asteroid can be served under a path prefix, e.g. /wiki (see Asteroid.Prefix):
+// its handlers see the path without it, and prepend basePath to the links they generate,
+// like the views do with {{ basePath }}.
+
+type basePathKey struct{}
+
+// withPrefix serves next under prefix, e.g. "/wiki/about.md" as "/about.md".
+// "/wiki" is redirected to "/wiki/", for relative links to resolve under the prefix.
+func withPrefix(prefix string, next http.Handler) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			to := prefix + "/"
+			if r.URL.RawQuery != "" {
+				to += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, to, http.StatusMovedPermanently)
+			return
+		}
+		p, ok := strings.CutPrefix(r.URL.Path, prefix+"/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		r2 := r.Clone(context.WithValue(r.Context(), basePathKey{}, prefix))
+		r2.URL.Path = "/" + p
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// basePath returns the prefix the request is served under, e.g. "/wiki", "" at the root.
+func basePath(r *http.Request) string {
+	prefix, _ := r.Context().Value(basePathKey{}).(string)
+	return prefix
+}
+
+var reRootLink = regexp.MustCompile(`(href|src)="(/[^/"][^"]*|/)"`)
+
+// prefixLinks prepends base to the root-relative links (e.g. href="/r/demo/users")
+// of html, e.g. rendered from markdown.
+func prefixLinks(html template.HTML, base string) template.HTML {
+	if base == "" {
+		return html
+	}
+	return template.HTML(reRootLink.ReplaceAllString(string(html), `$1="`+base+`$2"`))
+}
@@ -0,0 +1,39 @@
+package gnAsteroid
+
+import (
+	"html/template"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPrefix(t *testing.T) {
+	handler := withPrefix("/wiki/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(basePath(r) + " " + r.URL.Path))
+	}))
+	for _, tc := range []struct {
+		route  string
+		status int
+		body   string
+	}{
+		{"/wiki/", http.StatusOK, "/wiki /"},
+		{"/wiki/a/b.md", http.StatusOK, "/wiki /a/b.md"},
+		{"/wiki", http.StatusMovedPermanently, ""},
+		{"/wikipedia", http.StatusNotFound, ""},
+	} {
+		response := serveRoute(t, handler, tc.route, nil)
+		assert.Equal(t, tc.status, response.Code, tc.route)
+		if tc.body != "" {
+			assert.Equal(t, tc.body, response.Body.String(), tc.route)
+		}
+	}
+	response := serveRoute(t, handler, "/wiki?q=x", nil)
+	assert.Equal(t, "/wiki/?q=x", response.Header().Get("Location"))
+}
+
+func TestPrefixLinks(t *testing.T) {
+	html := template.HTML(`<a href="/r/demo/users">u</a> <a href="/">home</a> <img src="/img/a.svg"> <a href="rel.md">r</a> <a href="//example.com/">e</a> <a href="https://gno.land/">g</a>`)
+	assert.Equal(t, html, prefixLinks(html, ""))
+	assert.Equal(t, template.HTML(`<a href="/wiki/r/demo/users">u</a> <a href="/wiki/">home</a> <img src="/wiki/img/a.svg"> <a href="rel.md">r</a> <a href="//example.com/">e</a> <a href="https://gno.land/">g</a>`), prefixLinks(html, "/wiki"))
+}
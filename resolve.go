@@ -0,0 +1,96 @@
+package gnAsteroid
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// errForbiddenPath is returned by resolvePath for paths trying to escape the asteroid.
+var errForbiddenPath = errors.New("forbidden path")
+
+// resolvePath returns the name, in the asteroid FS, of the decoded url path
+// urlPath (i.e. r.URL.Path), e.g. "/subdir/page.md" -> "subdir/page.md", "/" -> ".".
+// Names are valid according to fs.ValidPath: paths having ".." elements,
+// backslashes or NUL bytes are refused with errForbiddenPath,
+// but file names merely containing dots (e.g. "a..b.md") are fine.
+func resolvePath(urlPath string) (string, error) {
+	if strings.ContainsAny(urlPath, "\\\x00") {
+		return "", errForbiddenPath
+	}
+	for _, elem := range strings.Split(urlPath, "/") {
+		if elem == ".." {
+			return "", errForbiddenPath
+		}
+	}
+	name := path.Clean("/" + urlPath)[1:] // e.g. "//a/./b/" -> "a/b"
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", errForbiddenPath
+	}
+	return name, nil
+}
+
+// ContainedDirFS is like os.DirFS(dir), but files and directories which are,
+// or are in, symlinks resolving outside of dir do not exist.
+// Symlinks within dir are followed.
+func ContainedDirFS(dir string) fs.FS {
+	root, e := filepath.Abs(dir)
+	if e == nil {
+		if real, e := filepath.EvalSymlinks(root); e == nil {
+			root = real
+		}
+	}
+	return containedDirFS{dir: dir, root: root, fsys: os.DirFS(dir)}
+}
+
+type containedDirFS struct {
+	dir  string
+	root string // dir, absolute and without symlinks
+	fsys fs.FS
+}
+
+// contained checks that name resolves within the root.
+func (c containedDirFS) contained(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	real, e := filepath.EvalSymlinks(filepath.Join(c.dir, filepath.FromSlash(name)))
+	if e != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if real, e = filepath.Abs(real); e != nil {
+		return &fs.PathError{Op: op, Path: name, Err: e}
+	}
+	if rel, e := filepath.Rel(c.root, real); e != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+func (c containedDirFS) Open(name string) (fs.File, error) {
+	if e := c.contained("open", name); e != nil {
+		return nil, e
+	}
+	return c.fsys.Open(name)
+}
+
+func (c containedDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if e := c.contained("readdir", name); e != nil {
+		return nil, e
+	}
+	entries, e := fs.ReadDir(c.fsys, name)
+	// entries escaping the root are left out
+	visible := entries[:0]
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink == 0 || c.contained("readdir", path.Join(name, entry.Name())) == nil {
+			visible = append(visible, entry)
+		}
+	}
+	return visible, e
+}
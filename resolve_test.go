@@ -0,0 +1,127 @@
+package gnAsteroid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePath(t *testing.T) {
+	for _, tc := range []struct {
+		urlPath, name string
+		forbidden     bool
+	}{
+		{"/", ".", false},
+		{"", ".", false},
+		{"/page.md", "page.md", false},
+		{"/subdir/deep/", "subdir/deep", false},
+		{"//subdir//./page.md", "subdir/page.md", false},
+		{"/a..b.md", "a..b.md", false},
+		{"/..a/b../...", "..a/b../...", false},
+		{"/%2e%2e/page.md", "%2e%2e/page.md", false}, // decoded once already: a literal name
+		{"/&lt;b&gt;.md", "&lt;b&gt;.md", false},
+		{"/..", "", true},
+		{"/../etc/passwd", "", true},
+		{"/subdir/../../etc/passwd", "", true},
+		{"/subdir/..", "", true},
+		{"..", "", true},
+		{"/subdir/..\\..\\secret", "", true},
+		{"/C:\\Windows\\win.ini", "", true},
+		{"/page.md\x00.png", "", true},
+	} {
+		name, e := resolvePath(tc.urlPath)
+		if tc.forbidden {
+			assert.ErrorIs(t, e, errForbiddenPath, tc.urlPath)
+		} else if assert.NoError(t, e, tc.urlPath) {
+			assert.Equal(t, tc.name, name, tc.urlPath)
+		}
+	}
+}
+
+func TestHostilePaths(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{
+		"index.md":       {Data: []byte("# Home")},
+		"a..b.md":        {Data: []byte("# Dots")},
+		"subdir/img.png": {Data: []byte("png")},
+	}, "Hostile")
+	handler := asteroid.Handler()
+	for _, tc := range []struct {
+		urlPath string
+		code    int
+	}{
+		{"/a..b.md", http.StatusOK},
+		{"/subdir//img.png", http.StatusOK},
+		{"/subdir/../../index.md", http.StatusForbidden},
+		{"/subdir/..\\..\\index.md", http.StatusForbidden},
+		{"/img.png\x00.md", http.StatusForbidden},
+		{"/%2e%2e/index.md", http.StatusNotFound}, // a literal name
+		{"/.hidden/../index.md", http.StatusForbidden},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.URL.Path = tc.urlPath // decoded, but not cleaned by a client
+		request.URL.RawPath = ""
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code == http.StatusMovedPermanently {
+			continue // cleaned by the router, which is fine too
+		}
+		assert.Equal(t, tc.code, response.Code, tc.urlPath)
+	}
+}
+
+func TestContainedDirFS(t *testing.T) {
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.md"), []byte("secret"), 0o644))
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.md"), []byte("# Home"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	for link, target := range map[string]string{
+		"escape.md":     filepath.Join(outside, "secret.md"),
+		"escape-dir":    outside,
+		"sub/up":        "../..",
+		"inside.md":     "index.md",
+		"sub/inside.md": "../index.md",
+	} {
+		if e := os.Symlink(target, filepath.Join(dir, link)); e != nil {
+			t.Skip("symlinks are not supported:", e)
+		}
+	}
+
+	// os.DirFS follows every symlink
+	_, e := os.DirFS(dir).Open("escape.md")
+	require.NoError(t, e)
+
+	contained := ContainedDirFS(dir)
+	for _, name := range []string{"index.md", "inside.md", "sub/inside.md", "sub"} {
+		f, e := contained.Open(name)
+		if assert.NoError(t, e, name) {
+			f.Close()
+		}
+	}
+	for _, name := range []string{"escape.md", "escape-dir", "escape-dir/secret.md", "sub/up", "sub/up/etc", "nothing.md"} {
+		_, e := contained.Open(name)
+		assert.ErrorIs(t, e, os.ErrNotExist, name)
+	}
+	for _, name := range []string{"../x", "/etc/passwd", "sub/../index.md"} {
+		_, e := contained.Open(name)
+		assert.ErrorIs(t, e, os.ErrInvalid, name)
+	}
+	var names []string
+	entries, e := os.ReadDir(dir)
+	require.NoError(t, e)
+	require.Len(t, entries, 5)
+	visible, e := contained.(interface {
+		ReadDir(string) ([]os.DirEntry, error)
+	}).ReadDir(".")
+	require.NoError(t, e)
+	for _, entry := range visible {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"index.md", "inside.md", "sub"}, names)
+}
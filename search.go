@@ -0,0 +1,287 @@
+package gnAsteroid
+
+import (
+	"encoding/json"
+	"html"
+	"html/template"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gotuna/gotuna"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Full-text search over the markdown pages of an asteroid.
+// The index is built with the asteroid's handler (so again on each reload),
+// and served as /search?q= (a themed page) and /search.json?q=.
+
+// field weights of a term in a page
+const (
+	weightTitle = 5
+	weightTags  = 3
+	weightPath  = 2
+	weightBody  = 1
+)
+
+const (
+	searchPageResults   = 50  // results shown by /search
+	searchDefaultLimit  = 20  // results returned by /search.json, unless ?limit=
+	searchMaxLimit      = 100 // max ?limit=
+	snippetBefore       = 60  // bytes of context before the first match
+	snippetLength       = 200 // bytes of a snippet
+	prefixMatchPenalty  = 0.5 // "aster" matches "asteroid", but ranks lower than "aster"
+	minPrefixMatchRunes = 2   // shorter query terms only match whole words
+)
+
+type searchIndex struct {
+	docs  []searchDoc
+	terms map[string]map[int]float64 // term -> doc -> weighted term frequency
+}
+
+type searchDoc struct {
+	Path  string // url path, e.g. "/subdir/page.md", or "/subdir/" for its index.md
+	Title string
+	Tags  []string
+	Body  string // plain text
+}
+
+// SearchResult is a page matching a query, as returned by /search.json.
+type SearchResult struct {
+	Path    string        `json:"path"`
+	Title   string        `json:"title"`
+	Tags    []string      `json:"tags,omitempty"`
+	Snippet string        `json:"snippet"`
+	Score   float64       `json:"score"`
+	HTML    template.HTML `json:"-"` // Snippet, with matches in <mark>
+}
+
+var textPolicy = bluemonday.StrictPolicy()
+
+// newSearchIndex indexes pages. name is the title of the home page.
+func newSearchIndex(pages []page, name string) *searchIndex {
+	idx := &searchIndex{terms: map[string]map[int]float64{}}
+	for _, p := range pages {
+		doc := searchDoc{Path: p.URL, Title: p.title(), Tags: p.Meta.Strings("tags")}
+		if p.URL == "/" && p.Meta.String("title") == "" {
+			doc.Title = name
+		}
+		if rendered, e := renderMarkdown(p.Markdown, asteroidPolicy); e == nil {
+			doc.Body = strings.Join(strings.Fields(html.UnescapeString(textPolicy.Sanitize(string(rendered)))), " ")
+		} else {
+			doc.Body = p.Markdown
+		}
+		idx.add(doc)
+	}
+	return idx
+}
+
+func (idx *searchIndex) add(doc searchDoc) {
+	i := len(idx.docs)
+	idx.docs = append(idx.docs, doc)
+	addTerms := func(text string, weight float64) {
+		for _, term := range tokenize(text) {
+			if idx.terms[term] == nil {
+				idx.terms[term] = map[int]float64{}
+			}
+			idx.terms[term][i] += weight
+		}
+	}
+	addTerms(doc.Title, weightTitle)
+	addTerms(strings.Join(doc.Tags, " "), weightTags)
+	addTerms(doc.Path, weightPath)
+	addTerms(doc.Body, weightBody)
+}
+
+// tokenize returns the lower-cased words of text.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// search returns pages matching every term of query, best first.
+func (idx *searchIndex) search(query string) []SearchResult {
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 {
+		return nil
+	}
+	scores := map[int]float64{}
+	for n, queryTerm := range queryTerms {
+		termScores := map[int]float64{}
+		for term, docs := range idx.terms {
+			match := 1.0
+			if term != queryTerm {
+				if utf8.RuneCountInString(queryTerm) < minPrefixMatchRunes || !strings.HasPrefix(term, queryTerm) {
+					continue
+				}
+				match = prefixMatchPenalty
+			}
+			idf := math.Log(1 + float64(len(idx.docs))/float64(len(docs)))
+			for doc, tf := range docs {
+				termScores[doc] += match * tf * idf
+			}
+		}
+		// pages must match every term
+		for doc, score := range termScores {
+			if n == 0 {
+				scores[doc] = score
+			} else if _, ok := scores[doc]; ok {
+				scores[doc] += score
+			}
+		}
+		for doc := range scores {
+			if _, ok := termScores[doc]; !ok {
+				delete(scores, doc)
+			}
+		}
+	}
+	results := make([]SearchResult, 0, len(scores))
+	for i, score := range scores {
+		doc := idx.docs[i]
+		snippet, snippetHTML := makeSnippet(doc.Body, queryTerms)
+		results = append(results, SearchResult{
+			Path:    doc.Path,
+			Title:   doc.Title,
+			Tags:    doc.Tags,
+			Snippet: snippet,
+			Score:   math.Round(score*1000) / 1000,
+			HTML:    snippetHTML,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	return results
+}
+
+// makeSnippet returns an excerpt of body around its first word matching
+// one of terms, as text and as html with matching words in <mark>.
+func makeSnippet(body string, terms []string) (string, template.HTML) {
+	type word struct{ start, end int }
+	var words []word // matching words, in order
+	start := -1
+	for i, r := range body + " " {
+		isWordRune := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if isWordRune && start == -1 {
+			start = i
+		} else if !isWordRune && start != -1 {
+			lower := strings.ToLower(body[start:i])
+			for _, term := range terms {
+				if lower == term || (utf8.RuneCountInString(term) >= minPrefixMatchRunes && strings.HasPrefix(lower, term)) {
+					words = append(words, word{start, i})
+					break
+				}
+			}
+			start = -1
+		}
+	}
+	from := 0
+	if len(words) > 0 && words[0].start > snippetBefore {
+		from = words[0].start - snippetBefore
+		if space := strings.IndexByte(body[from:words[0].start], ' '); space != -1 {
+			from += space + 1
+		}
+		for !utf8.RuneStart(body[from]) {
+			from++
+		}
+	}
+	to := len(body)
+	if to-from > snippetLength {
+		to = from + snippetLength
+		if space := strings.LastIndexByte(body[from:to], ' '); space > snippetLength/2 {
+			to = from + space
+		}
+		for to > from && !utf8.RuneStart(body[to]) {
+			to--
+		}
+	}
+	prefix, suffix := "", ""
+	if from > 0 {
+		prefix = "…"
+	}
+	if to < len(body) {
+		suffix = "…"
+	}
+	var b strings.Builder
+	b.WriteString(prefix)
+	last := from
+	for _, w := range words {
+		if w.start < from || w.end > to {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(body[last:w.start]))
+		b.WriteString("<mark>" + template.HTMLEscapeString(body[w.start:w.end]) + "</mark>")
+		last = w.end
+	}
+	b.WriteString(template.HTMLEscapeString(body[last:to]))
+	b.WriteString(suffix)
+	return prefix + body[from:to] + suffix, template.HTML(b.String())
+}
+
+// handleSearch serves the themed search page, /search?q=
+func (a *Asteroid) handleSearch(idx *searchIndex, app gotuna.App, cfg *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		results := underBase(idx.search(query), basePath(r))
+		total := len(results)
+		if len(results) > searchPageResults {
+			results = results[:searchPageResults]
+		}
+		app.NewTemplatingEngine().
+			Set("AsteroidName", a.Name).
+			Set("AtHome", "0").
+			Set("PageName", "Search").
+			Set("Query", query).
+			Set("Results", results).
+			Set("Total", total).
+			Set("Config", cfg).
+			Render(w, r, "asteroid_search.html", "funcs.html")
+	})
+}
+
+// handleSearchJSON serves /search.json?q=&limit=
+func (a *Asteroid) handleSearchJSON(idx *searchIndex) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		limit := searchDefaultLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, e := strconv.Atoi(s)
+			if e != nil || n < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, searchMaxLimit)
+		}
+		results := underBase(idx.search(query), basePath(r))
+		if results == nil {
+			results = []SearchResult{}
+		}
+		total := len(results)
+		if len(results) > limit {
+			results = results[:limit]
+		}
+		out, _ := json.MarshalIndent(struct {
+			Query   string         `json:"query"`
+			Total   int            `json:"total"`
+			Results []SearchResult `json:"results"`
+		}{query, total, results}, "", "  ")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(out)
+	})
+}
+
+// underBase prepends base (see basePath) to the paths of results.
+func underBase(results []SearchResult, base string) []SearchResult {
+	for i := range results {
+		results[i].Path = base + results[i].Path
+	}
+	return results
+}
@@ -0,0 +1,114 @@
+package gnAsteroid
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var searchFS = fstest.MapFS{
+	"index.md":          {Data: []byte("# Welcome\n\nThis wiki is about comets and asteroids.")},
+	"comets.md":         {Data: []byte("---\ntitle: Comets\ntags: [ice, tails]\n---\nComets are icy bodies with a tail.")},
+	"rocks/README.md":   {Data: []byte("Asteroids are rocky. Unlike comets, they have no tail.")},
+	"rocks/ceres.md":    {Data: []byte("Ceres is the largest object in the *asteroid* belt. " + strings.Repeat("Filler text. ", 30) + "Ceres has ice.")},
+	".drafts/secret.md": {Data: []byte("secret comets")},
+	"picture.png":       {Data: []byte("comets")},
+}
+
+func TestSearchIndex(t *testing.T) {
+	idx := newSearchIndex(loadPages(slog.Default(), searchFS), "Space")
+	paths := func(results []SearchResult) (paths []string) {
+		for _, r := range results {
+			paths = append(paths, r.Path)
+		}
+		return paths
+	}
+
+	// the title, then tags, weigh more than the body
+	results := idx.search("comets")
+	assert.Equal(t, []string{"/comets.md", "/", "/rocks/"}, paths(results))
+	assert.Equal(t, "Comets", results[0].Title)
+	assert.Equal(t, []string{"ice", "tails"}, results[0].Tags)
+	assert.Equal(t, "Space", results[1].Title)
+
+	// every term must match
+	assert.Equal(t, []string{"/rocks/"}, paths(idx.search("Comets ROCKY")))
+	assert.Empty(t, idx.search("comets granite"))
+	assert.Empty(t, idx.search("  "))
+	assert.Empty(t, idx.search("secret"))
+
+	// prefixes match too, but rank lower
+	assert.Equal(t, []string{"/comets.md", "/rocks/ceres.md"}, paths(idx.search("ice")))
+	assert.Equal(t, []string{"/rocks/ceres.md", "/", "/rocks/"}, paths(idx.search("asteroid")))
+
+	// snippets are taken around the first match, markdown is rendered as text
+	results = idx.search("ceres ice")
+	require.Len(t, results, 1)
+	assert.True(t, strings.HasPrefix(results[0].Snippet, "Ceres is the largest object in the asteroid belt."), results[0].Snippet)
+	assert.Contains(t, string(results[0].HTML), "<mark>Ceres</mark> is the largest")
+	results = idx.search("icy")
+	require.Len(t, results, 1)
+	assert.Equal(t, "Comets are icy bodies with a tail.", results[0].Snippet)
+	assert.Equal(t, "Comets are <mark>icy</mark> bodies with a tail.", string(results[0].HTML))
+}
+
+func TestMakeSnippet(t *testing.T) {
+	body := strings.Repeat("blah ", 40) + "needle <b> " + strings.Repeat("blah ", 60)
+	snippet, html := makeSnippet(body, []string{"needle"})
+	assert.True(t, strings.HasPrefix(snippet, "…blah"), snippet)
+	assert.True(t, strings.HasSuffix(snippet, "blah…"), snippet)
+	assert.LessOrEqual(t, len(snippet), snippetLength+2*len("…"))
+	assert.Contains(t, string(html), "<mark>needle</mark> &lt;b&gt;")
+
+	// cut between words, never inside a rune
+	snippet, _ = makeSnippet(strings.Repeat("é", 100)+" needle", []string{"needle"})
+	assert.Equal(t, "…needle", snippet)
+	snippet, _ = makeSnippet("needle "+strings.Repeat("é", 200), []string{"needle"})
+	assert.True(t, strings.HasPrefix(snippet, "needle é"), snippet)
+	assert.NotContains(t, snippet, "\uFFFD")
+}
+
+func TestSearchHandlers(t *testing.T) {
+	handler := NewAsteroid(searchFS, "Space").Handler()
+
+	response := serveRoute(t, handler, "/search?q=tail", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `<a href="/comets.md">Comets</a>`)
+	assert.Contains(t, response.Body.String(), "<mark>tail</mark>")
+	assert.Contains(t, response.Body.String(), "2 results")
+
+	response = serveRoute(t, handler, "/search.json?q=comets&limit=2", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	var out struct {
+		Query   string
+		Total   int
+		Results []SearchResult
+	}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &out))
+	assert.Equal(t, "comets", out.Query)
+	assert.Equal(t, 3, out.Total)
+	require.Len(t, out.Results, 2)
+	assert.Equal(t, "/comets.md", out.Results[0].Path)
+	assert.NotEmpty(t, out.Results[0].Snippet)
+
+	response = serveRoute(t, handler, "/search.json?q=", nil)
+	assert.JSONEq(t, `{"query": "", "total": 0, "results": []}`, response.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serveRoute(t, handler, "/search.json?q=x&limit=-1", nil).Code)
+
+	// under a prefix, e.g. a -vhost
+	a := NewAsteroid(searchFS, "Space")
+	a.Prefix = "/wiki"
+	handler = a.Handler()
+	response = serveRoute(t, handler, "/wiki/search?q=tail", nil)
+	assert.Contains(t, response.Body.String(), `action="/wiki/search"`)
+	assert.Contains(t, response.Body.String(), `<a href="/wiki/comets.md">Comets</a>`)
+	require.NoError(t, json.Unmarshal(serveRoute(t, handler, "/wiki/search.json?q=comets", nil).Body.Bytes(), &out))
+	assert.Equal(t, "/wiki/comets.md", out.Results[0].Path)
+}
@@ -0,0 +1,122 @@
+package gnAsteroid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+)
+
+// When Config.SnapshotDir is set, successful realm and package queries are
+// saved there, and served instead (marked as stale) when the chain is unreachable.
+
+// snapshot tells when a query result served from the snapshot directory was fetched.
+type snapshot struct {
+	Height    int64     `json:"height"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// snapshotFile is the content of a file in the snapshot directory.
+type snapshotFile struct {
+	snapshot
+	Path  string `json:"path"`
+	Data  string `json:"data"`
+	Value []byte `json:"value"`
+	Res   []byte `json:"res"` // ResponseQuery.Data
+}
+
+type snapshotStore struct {
+	dir    string
+	logger *slog.Logger
+}
+
+// newSnapshotStore returns nil if dir is empty.
+func newSnapshotStore(logger *slog.Logger, dir string) *snapshotStore {
+	if dir == "" {
+		return nil
+	}
+	return &snapshotStore{dir: dir, logger: logger}
+}
+
+// snapshotted tells whether results of qpath are saved.
+func snapshotted(qpath string) bool {
+	switch qpath {
+	case "vm/qrender", qFileStr, "vm/qfuncs":
+		return true
+	}
+	return false
+}
+
+// filename is e.g. <dir>/vm_qrender/<sha256 of data>.json
+func (s *snapshotStore) filename(qpath string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return filepath.Join(s.dir, strings.ReplaceAll(qpath, "/", "_"), hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *snapshotStore) save(qpath string, data []byte, res *abci.ResponseQuery) {
+	if !snapshotted(qpath) {
+		return
+	}
+	content, err := json.Marshal(snapshotFile{
+		snapshot: snapshot{Height: res.Height, FetchedAt: time.Now()},
+		Path:     qpath,
+		Data:     string(data),
+		Value:    res.Value,
+		Res:      res.Data,
+	})
+	if err != nil {
+		s.logger.Error("snapshot", "path", qpath, "error", err)
+		return
+	}
+	filename := s.filename(qpath, data)
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		s.logger.Error("snapshot", "path", qpath, "error", err)
+		return
+	}
+	// write then rename, so a snapshot is never read half written,
+	// to a temp file of its own, as a request and a refresh may save the same snapshot
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		s.logger.Error("snapshot", "path", qpath, "error", err)
+		return
+	}
+	_, err = tmp.Write(content)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		s.logger.Error("snapshot", "path", qpath, "error", err)
+		os.Remove(tmp.Name())
+	}
+}
+
+// load returns the last saved result of a query, nil if there is none.
+func (s *snapshotStore) load(qpath string, data []byte) (*abci.ResponseQuery, *snapshot) {
+	if !snapshotted(qpath) {
+		return nil, nil
+	}
+	content, err := os.ReadFile(s.filename(qpath, data))
+	if err != nil {
+		return nil, nil
+	}
+	var file snapshotFile
+	if err := json.Unmarshal(content, &file); err != nil || file.Path != qpath || file.Data != string(data) {
+		s.logger.Error("invalid snapshot", "path", qpath, "error", err)
+		return nil, nil
+	}
+	res := &abci.ResponseQuery{Value: file.Value, Height: file.Height}
+	res.Data = file.Res
+	return res, &file.snapshot
+}
@@ -0,0 +1,86 @@
+package gnAsteroid
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	abci "github.com/gnolang/gno/tm2/pkg/bft/abci/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshots(t *testing.T) {
+	node := echoNode(t, 0)
+	cfg := configWith(node.URL)
+	cfg.SnapshotDir = t.TempDir()
+	chain := NewChainClient(slog.Default(), &cfg)
+	ctx := context.Background()
+
+	res, e := chain.makeRequest(ctx, "vm/qrender", []byte("gno.land/r/demo/users:"))
+	require.NoError(t, e)
+	assert.Nil(t, res.Snapshot)
+	_, e = chain.makeRequest(ctx, ".app/version", nil) // not snapshotted
+	require.NoError(t, e)
+
+	node.Close()
+	res, e = chain.makeRequest(ctx, "vm/qrender", []byte("gno.land/r/demo/users:"))
+	require.NoError(t, e)
+	require.NotNil(t, res.Snapshot)
+	assert.Equal(t, "vm/qrender:gno.land/r/demo/users:", string(res.Data))
+	assert.Equal(t, int64(42), res.Snapshot.Height)
+	assert.False(t, res.Snapshot.FetchedAt.IsZero())
+
+	_, e = chain.makeRequest(ctx, "vm/qrender", []byte("gno.land/r/demo/boards:"))
+	assert.ErrorIs(t, e, errChainUnreachable, "never fetched")
+	_, e = chain.makeRequest(ctx, ".app/version", nil)
+	assert.ErrorIs(t, e, errChainUnreachable)
+}
+
+func TestSnapshotsSavedConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	store := newSnapshotStore(slog.Default(), dir)
+	res := &abci.ResponseQuery{Height: 42}
+	res.Data = []byte(strings.Repeat("x", 1<<20))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.save("vm/qrender", []byte("gno.land/r/demo/users:"), res)
+		}()
+	}
+	wg.Wait()
+	loaded, _ := store.load("vm/qrender", []byte("gno.land/r/demo/users:"))
+	require.NotNil(t, loaded)
+	assert.Equal(t, res.Data, loaded.Data)
+	tmps, e := filepath.Glob(filepath.Join(dir, "*", "*.tmp"))
+	require.NoError(t, e)
+	assert.Empty(t, tmps)
+}
+
+func TestSnapshotBanner(t *testing.T) {
+	node := echoNode(t, 0)
+	cfg := NewDefaultConfig()
+	cfg.RemoteAddr = node.URL
+	cfg.SnapshotDir = t.TempDir()
+	handler := (&Asteroid{
+		FS:     fstest.MapFS{"index.md": {Data: []byte("# Home")}},
+		Name:   "Offline",
+		Config: cfg,
+	}).Handler()
+	response := serveRoute(t, handler, "/r/demo/users", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.NotContains(t, response.Body.String(), "snapshot_banner")
+
+	node.Close()
+	response = serveRoute(t, handler, "/r/demo/users", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "vm/qrender:gno.land/r/demo/users:")
+	assert.Contains(t, response.Body.String(), "this is a snapshot of height 42")
+}
@@ -0,0 +1,19 @@
+// Refreshes the page when the asteroid changes, or only its stylesheets when
+// the theme css changes. Included when gnAsteroid runs with -livereload.
+(function () {
+  var url = document.currentScript.dataset.url;
+  if (!url || !window.EventSource) {
+    return;
+  }
+  var source = new EventSource(url);
+  source.addEventListener("reload", function () {
+    window.location.reload();
+  });
+  source.addEventListener("css", function () {
+    document.querySelectorAll('link[rel="stylesheet"]').forEach(function (link) {
+      var href = new URL(link.href);
+      href.searchParams.set("livereload", Date.now());
+      link.href = href.toString();
+    });
+  });
+})();
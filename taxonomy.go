@@ -0,0 +1,148 @@
+package gnAsteroid
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"github.com/gotuna/gotuna"
+)
+
+// Taxonomies group pages by the terms of a front matter list, e.g. tags:
+// /tags/ lists every tag, /tags/gno/ lists the pages tagged "gno".
+// Which front matter keys are taxonomies is set by Asteroid.Taxonomies.
+
+// DefaultTaxonomies are the taxonomies of an asteroid whose Taxonomies are nil.
+var DefaultTaxonomies = []string{"tags"}
+
+// reTaxonomy matches valid taxonomy names, which are also url paths.
+var reTaxonomy = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+type taxonomy struct {
+	Name   string          // front matter key, e.g. "tags"
+	Terms  []*taxonomyTerm // sorted by name
+	bySlug map[string]*taxonomyTerm
+}
+
+type taxonomyTerm struct {
+	Name  string // as first found, e.g. "Go Lang"
+	Slug  string // url path element, e.g. "go-lang"
+	Pages []taxonomyPage
+}
+
+type taxonomyPage struct {
+	URL   string
+	Title string
+	Date  time.Time // zero if the page has no date
+}
+
+// URL returns the url path listing the pages of the term, e.g. "/tags/go-lang/".
+func (t *taxonomyTerm) URL(taxonomy string) string {
+	return "/" + taxonomy + "/" + t.Slug + "/"
+}
+
+// newTaxonomy groups pages by the terms of their front matter list name.
+func newTaxonomy(pages []page, name string) *taxonomy {
+	t := &taxonomy{Name: name, bySlug: map[string]*taxonomyTerm{}}
+	for _, p := range pages {
+		date, _ := p.date()
+		seen := map[string]bool{} // a term listed twice in a page
+		for _, term := range p.Meta.Strings(name) {
+			slug := termSlug(term)
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			tt, ok := t.bySlug[slug]
+			if !ok {
+				tt = &taxonomyTerm{Name: term, Slug: slug}
+				t.bySlug[slug] = tt
+				t.Terms = append(t.Terms, tt)
+			}
+			tt.Pages = append(tt.Pages, taxonomyPage{URL: p.URL, Title: p.title(), Date: date})
+		}
+	}
+	sort.Slice(t.Terms, func(i, j int) bool {
+		return t.Terms[i].Slug < t.Terms[j].Slug
+	})
+	for _, tt := range t.Terms {
+		pages := tt.Pages
+		// newest first, then undated pages, by title
+		sort.SliceStable(pages, func(i, j int) bool {
+			if !pages[i].Date.Equal(pages[j].Date) {
+				return pages[i].Date.After(pages[j].Date)
+			}
+			return strings.ToLower(pages[i].Title) < strings.ToLower(pages[j].Title)
+		})
+	}
+	return t
+}
+
+// termSlug returns term lower-cased, with runs of anything but letters and digits replaced by "-".
+func termSlug(term string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(term) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// taxonomies returns the valid names of a.Taxonomies, or DefaultTaxonomies.
+func (a *Asteroid) taxonomies() []string {
+	if a.Taxonomies == nil {
+		return DefaultTaxonomies
+	}
+	var names []string
+	for _, name := range a.Taxonomies {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "/"))
+		if !reTaxonomy.MatchString(name) {
+			a.logger().Warn("invalid taxonomy, ignored", "taxonomy", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// handleTaxonomy serves the terms of t, or the pages of the term in the route variable "term".
+func (a *Asteroid) handleTaxonomy(t *taxonomy, app gotuna.App, cfg *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := mux.Vars(r)["term"]
+		if slug == "" {
+			app.NewTemplatingEngine().
+				Set("AsteroidName", a.Name).
+				Set("AtHome", "0").
+				Set("PageName", t.Name).
+				Set("Taxonomy", t).
+				Set("Config", cfg).
+				Render(w, r, "asteroid_taxonomy.html", "funcs.html")
+			return
+		}
+		term, ok := t.bySlug[slug]
+		if !ok {
+			a.notFound(w, r, app, cfg)
+			return
+		}
+		app.NewTemplatingEngine().
+			Set("AsteroidName", a.Name).
+			Set("AtHome", "0").
+			Set("PageName", t.Name+": "+term.Name).
+			Set("Taxonomy", t).
+			Set("Term", term).
+			Set("Config", cfg).
+			Render(w, r, "asteroid_term.html", "funcs.html")
+	})
+}
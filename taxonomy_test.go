@@ -0,0 +1,93 @@
+package gnAsteroid
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var taxonomyFS = fstest.MapFS{
+	"index.md":       {Data: []byte("# Home")},
+	"first.md":       {Data: []byte("---\ntitle: First\ndate: 2024-01-02\ntags: [Gno Land, go]\nseries: intro\n---\nHello.")},
+	"second.md":      {Data: []byte("---\ntitle: Second\ndate: 2024-03-04\ntags: [gno land, gno-land]\n---\nAgain.")},
+	"undated.md":     {Data: []byte("+++\ntitle = \"Undated\"\ntags = [\"go\"]\n+++\nNo date.")},
+	".hidden/tag.md": {Data: []byte("---\ntags: [hidden]\n---\nNot listed.")},
+}
+
+func TestNewTaxonomy(t *testing.T) {
+	tags := newTaxonomy(loadPages(slog.Default(), taxonomyFS), "tags")
+	require.Len(t, tags.Terms, 2)
+	assert.Equal(t, "Gno Land", tags.Terms[0].Name)
+	assert.Equal(t, "gno-land", tags.Terms[0].Slug)
+	assert.Equal(t, "/tags/gno-land/", tags.Terms[0].URL("tags"))
+	require.Len(t, tags.Terms[0].Pages, 2) // "gno land" and "gno-land" are the same term
+	assert.Equal(t, "Second", tags.Terms[0].Pages[0].Title)
+	assert.Equal(t, "First", tags.Terms[0].Pages[1].Title)
+	assert.Equal(t, "go", tags.Terms[1].Slug)
+	require.Len(t, tags.Terms[1].Pages, 2)
+	assert.Equal(t, "/first.md", tags.Terms[1].Pages[0].URL)
+	assert.Equal(t, "Undated", tags.Terms[1].Pages[1].Title)
+}
+
+func TestTermSlug(t *testing.T) {
+	for term, slug := range map[string]string{
+		"Go":            "go",
+		" Gno  Land! ":  "gno-land",
+		"c++/rust":      "c-rust",
+		"snake_case":    "snake_case",
+		"Été 2024":      "été-2024",
+		"../etc/passwd": "etc-passwd",
+		"!!!":           "",
+	} {
+		assert.Equal(t, slug, termSlug(term), term)
+	}
+}
+
+func TestTaxonomyHandlers(t *testing.T) {
+	asteroid := NewAsteroid(taxonomyFS, "Taxonomies")
+	response := serveRoute(t, asteroid.Handler(), "/tags/", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `<a href="/tags/gno-land/">Gno Land</a> <span class="taxonomy_count">2</span>`)
+	assert.NotContains(t, response.Body.String(), "hidden")
+	for _, route := range []string{"/tags/go/", "/tags/go"} {
+		response = serveRoute(t, asteroid.Handler(), route, nil)
+		require.Equal(t, http.StatusOK, response.Code, route)
+		assert.Contains(t, response.Body.String(), `<a href="/first.md">First</a> <time class="taxonomy_date" datetime="2024-01-02">`)
+		assert.Contains(t, response.Body.String(), `<a href="/undated.md">Undated</a>`)
+	}
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), "/tags/nothing/", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), "/series/", nil).Code)
+
+	asteroid.Taxonomies = []string{"Series", "../bad"}
+	response = serveRoute(t, asteroid.Handler(), "/series/intro/", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `<a href="/first.md">First</a>`)
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, asteroid.Handler(), "/tags/", nil).Code)
+}
+
+func TestTaxonomyUnderPrefix(t *testing.T) {
+	asteroid := NewAsteroid(taxonomyFS, "Taxonomies")
+	asteroid.Prefix = "/wiki"
+	response := serveRoute(t, asteroid.Handler(), "/wiki/tags/go/", nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `<a href="/wiki/tags/">All tags</a>`)
+	assert.Contains(t, response.Body.String(), `<a href="/wiki/first.md">First</a>`)
+}
+
+func TestGeneratedPagesShadowed(t *testing.T) {
+	asteroid := NewAsteroid(fstest.MapFS{
+		"index.md":        {Data: []byte("# Home")},
+		"first.md":        {Data: []byte("---\ntags: [go]\n---\nHello.")},
+		"tags/index.md":   {Data: []byte("# My own tags")},
+		"search/index.md": {Data: []byte("# My own search")},
+	}, "Shadowed")
+	handler := asteroid.Handler()
+	assert.Contains(t, serveRoute(t, handler, "/tags/", nil).Body.String(), "My own tags")
+	assert.Equal(t, http.StatusNotFound, serveRoute(t, handler, "/tags/go/", nil).Code)
+	assert.Contains(t, serveRoute(t, handler, "/search/", nil).Body.String(), "My own search")
+	assert.Equal(t, http.StatusOK, serveRoute(t, handler, "/search.json?q=hello", nil).Code, "not shadowed")
+}
@@ -0,0 +1,186 @@
+package gnAsteroid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/gotuna/gotuna"
+	toml "github.com/pelletier/go-toml"
+)
+
+// Themes: besides its ThemeFS, served at /static/, an asteroid can be served
+// with several named themes (Asteroid.Themes), each served at /static/themes/<name>/.
+// Readers pick one with ?theme=<name>, which is remembered in a cookie, or
+// with the selector of header_buttons. Authors can pin the default theme, and
+// restrict the ones readers can pick, in the SettingsFile of their asteroid:
+//
+//	theme = "readable"
+//	themes = ["readable", "raw"]
+//
+// A theme can also change the HTML of the pages, with views in its ThemeViewsDir.
+
+// ThemeViewsDir is the optional directory of a theme whose views replace
+// those of the asteroid and of gnoweb, e.g. views/funcs.html to change header_buttons.
+const ThemeViewsDir = "views"
+
+// SettingsFile is the name of the settings file, at the root of an asteroid.
+const SettingsFile = ".asteroid.toml"
+
+// ThemeParam is the query parameter, and themeCookie the cookie, choosing the theme of a reader.
+const ThemeParam = "theme"
+const themeCookie = "asteroid_theme"
+
+var reThemeName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// settings is the content of the SettingsFile.
+type settings struct {
+	Theme  string   `toml:"theme"`  // default theme, e.g. "readable". ThemeFS if empty
+	Themes []string `toml:"themes"` // the themes readers can pick. all the installed ones if empty
+}
+
+// loadSettings reads the SettingsFile of asteroid, if any.
+func loadSettings(logger *slog.Logger, asteroid fs.FS) settings {
+	var s settings
+	if p, ok := asteroid.(*publishedFS); ok {
+		asteroid = p.fsys // dotfiles are hidden
+	}
+	content, e := fs.ReadFile(asteroid, SettingsFile)
+	if e != nil {
+		if !errors.Is(e, fs.ErrNotExist) {
+			logger.Error("reading "+SettingsFile, "error", e)
+		}
+		return s
+	}
+	if e := toml.Unmarshal(content, &s); e != nil {
+		logger.Warn("invalid "+SettingsFile+", ignored", "error", e)
+		return settings{}
+	}
+	return s
+}
+
+// themeChoice is the themes readers can pick.
+type themeChoice struct {
+	names []string // sorted
+	def   string   // the default theme, "" for ThemeFS
+
+	schemes map[string][]string // theme -> Manifest.ColorSchemes
+}
+
+// themeChoice returns the installed themes readers can pick, according to s.
+func (a *Asteroid) themeChoice(s settings) themeChoice {
+	var c themeChoice
+	allowed := map[string]bool{}
+	for _, name := range s.Themes {
+		allowed[name] = true
+	}
+	for name := range a.Themes {
+		if !reThemeName.MatchString(name) {
+			a.logger().Warn(fmt.Sprintf("invalid theme name %q, ignored, expected %s", name, reThemeName))
+		} else if len(allowed) == 0 || allowed[name] {
+			c.names = append(c.names, name)
+		}
+	}
+	sort.Strings(c.names)
+	if s.Theme != "" {
+		if c.allowed(s.Theme) {
+			c.def = s.Theme
+		} else {
+			a.logger().Warn(fmt.Sprintf("%s: theme %q is not installed, or not allowed", SettingsFile, s.Theme))
+		}
+	}
+	if c.def == "" && len(allowed) > 0 && len(c.names) > 0 {
+		c.def = c.names[0] // ThemeFS is not allowed
+	}
+	return c
+}
+
+func (c themeChoice) allowed(name string) bool {
+	i := sort.SearchStrings(c.names, name)
+	return i < len(c.names) && c.names[i] == name
+}
+
+type themeKey struct{}
+
+// handler serves next with the theme chosen by the reader (see themeOf),
+// or routers[theme] if any, remembering a ?theme= choice in a cookie.
+func (c themeChoice) handler(next http.Handler, routers map[string]http.Handler) http.Handler {
+	if len(c.names) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		theme := c.def
+		if cookie, e := r.Cookie(themeCookie); e == nil && c.allowed(cookie.Value) {
+			theme = cookie.Value
+		}
+		if r.URL.Query().Has(ThemeParam) {
+			theme = r.URL.Query().Get(ThemeParam)
+			cookie := &http.Cookie{Name: themeCookie, Value: theme, Path: basePath(r) + "/", MaxAge: 365 * 24 * 3600, SameSite: http.SameSiteLaxMode}
+			if theme == c.def || !c.allowed(theme) {
+				theme = c.def
+				cookie.Value, cookie.MaxAge = "", -1 // back to the default
+			}
+			http.SetCookie(w, cookie)
+		}
+		w.Header().Add("Vary", "Cookie")
+		handler := next
+		if router, ok := routers[theme]; ok {
+			handler = router
+		}
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), themeKey{}, theme)))
+	})
+}
+
+// themeOf returns the theme of the request, "" for ThemeFS.
+func themeOf(r *http.Request) string {
+	theme, _ := r.Context().Value(themeKey{}).(string)
+	return theme
+}
+
+// themePath returns the url path of the theme named name, "" for ThemeFS.
+func themePath(name string) string {
+	if name == "" {
+		return "/static/"
+	}
+	return "/static/themes/" + name + "/"
+}
+
+// themeOption is an option of the theme selector.
+type themeOption struct {
+	Name     string
+	Selected bool
+}
+
+// viewHelpers are the template functions of the themes:
+// themePath, e.g. "/static/themes/raw/", themes, the options of the selector,
+// and colorSchemes, e.g. ["light"].
+func (c themeChoice) viewHelpers() []gotuna.ViewHelperFunc {
+	return []gotuna.ViewHelperFunc{
+		func(w http.ResponseWriter, r *http.Request) (string, interface{}) {
+			return "themePath", func() string { return basePath(r) + themePath(themeOf(r)) }
+		},
+		func(w http.ResponseWriter, r *http.Request) (string, interface{}) {
+			return "themes", func() []themeOption {
+				var options []themeOption
+				if c.def == "" {
+					options = append(options, themeOption{Name: "default", Selected: themeOf(r) == ""})
+				}
+				for _, name := range c.names {
+					options = append(options, themeOption{Name: name, Selected: themeOf(r) == name})
+				}
+				if len(options) < 2 {
+					return nil // nothing to choose from
+				}
+				return options
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) (string, interface{}) {
+			return "colorSchemes", func() []string { return c.schemes[themeOf(r)] }
+		},
+	}
+}
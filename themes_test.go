@@ -0,0 +1,111 @@
+package gnAsteroid
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSettings(t *testing.T) {
+	asteroid := fstest.MapFS{
+		SettingsFile: {Data: []byte("theme = \"raw\"\nthemes = [\"raw\", \"readable\"]\n")},
+	}
+	want := settings{Theme: "raw", Themes: []string{"raw", "readable"}}
+	assert.Equal(t, want, loadSettings(slog.Default(), asteroid))
+	assert.Equal(t, want, loadSettings(slog.Default(), (&Asteroid{FS: asteroid}).published().FS), "dotfiles are hidden, but not to the settings")
+	assert.Equal(t, settings{}, loadSettings(slog.Default(), fstest.MapFS{}))
+	assert.Equal(t, settings{}, loadSettings(slog.Default(), fstest.MapFS{SettingsFile: {Data: []byte("theme = ")}}))
+}
+
+func TestThemeChoice(t *testing.T) {
+	a := &Asteroid{Themes: map[string]fs.FS{"raw": nil, "readable": nil, "cloudy": nil, "Not Valid": nil}}
+	for _, tc := range []struct {
+		settings settings
+		want     themeChoice
+	}{
+		{settings{}, themeChoice{names: []string{"cloudy", "raw", "readable"}}},
+		{settings{Theme: "raw"}, themeChoice{names: []string{"cloudy", "raw", "readable"}, def: "raw"}},
+		{settings{Theme: "raw", Themes: []string{"readable", "raw", "bubbly"}}, themeChoice{names: []string{"raw", "readable"}, def: "raw"}},
+		{settings{Themes: []string{"readable", "raw"}}, themeChoice{names: []string{"raw", "readable"}, def: "raw"}},
+		{settings{Theme: "bubbly"}, themeChoice{names: []string{"cloudy", "raw", "readable"}}},
+	} {
+		assert.Equal(t, tc.want, a.themeChoice(tc.settings), tc.settings)
+	}
+}
+
+func TestThemeSwitching(t *testing.T) {
+	asteroid := fstest.MapFS{"index.md": {Data: []byte("# Home")}}
+	a := NewAsteroid(asteroid, "Juno")
+	a.Themes = map[string]fs.FS{
+		"raw":      fstest.MapFS{"css/common.css": {Data: []byte("raw css")}},
+		"readable": fstest.MapFS{"css/common.css": {Data: []byte("readable css")}},
+	}
+	get := func(route string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		var header http.Header
+		if cookie != nil {
+			header = http.Header{"Cookie": {cookie.String()}}
+		}
+		response := serveRoute(t, a.Handler(), route, header)
+		require.Equal(t, http.StatusOK, response.Code, route)
+		return response
+	}
+
+	response := get("/", nil)
+	assert.Contains(t, response.Body.String(), `href="/static/css/common.css"`)
+	assert.Contains(t, response.Body.String(), `<option value="default" selected>`)
+	assert.Contains(t, response.Body.String(), `<option value="raw">`)
+
+	response = get("/?theme=raw", nil)
+	assert.Contains(t, response.Body.String(), `href="/static/themes/raw/css/common.css"`)
+	assert.Contains(t, response.Body.String(), `<option value="raw" selected>`)
+	assert.Contains(t, response.Body.String(), `href="/static/css/components.css"`, "whatever the theme")
+	cookies := response.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "raw", cookies[0].Value)
+
+	response = get("/", cookies[0])
+	assert.Contains(t, response.Body.String(), `href="/static/themes/raw/css/common.css"`)
+	response = get("/?theme=nope", cookies[0])
+	assert.Contains(t, response.Body.String(), `href="/static/css/common.css"`)
+	assert.Equal(t, -1, response.Result().Cookies()[0].MaxAge, "the cookie is removed")
+
+	assert.Equal(t, "readable css", get("/static/themes/readable/css/common.css", nil).Body.String())
+	assert.Contains(t, get("/static/themes/readable/css/normalize.css", nil).Body.String(), "normalize.css", "from static/")
+	assert.Contains(t, get("/static/css/components.css", nil).Body.String(), ".snapshot_banner")
+
+	// pinned by the author
+	asteroid[SettingsFile] = &fstest.MapFile{Data: []byte("theme = \"readable\"\nthemes = [\"readable\"]\n")}
+	response = get("/?theme=raw", nil)
+	assert.Contains(t, response.Body.String(), `href="/static/themes/readable/css/common.css"`)
+	assert.NotContains(t, response.Body.String(), `theme_selector`, "nothing to choose from")
+}
+
+func TestThemeViews(t *testing.T) {
+	notFound := func(message string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`{{ define "app" }}<link href="{{ themePath }}css/common.css">` + message + `{{ end }}`)}
+	}
+	a := NewAsteroid(fstest.MapFS{"index.md": {Data: []byte("# Home")}}, "Juno")
+	a.ThemeFS = fstest.MapFS{"views/404.html": notFound("Lost in space")}
+	a.Themes = map[string]fs.FS{
+		"raw":     fstest.MapFS{"css/common.css": {Data: []byte("raw css")}},
+		"sidebar": fstest.MapFS{"views/404.html": notFound("Lost in the sidebar")},
+	}
+	handler := a.Handler()
+	get := func(route string) string {
+		response := serveRoute(t, handler, route, nil)
+		assert.Equal(t, http.StatusNotFound, response.Code, route)
+		return response.Body.String()
+	}
+	assert.Contains(t, get("/nope"), "Lost in space")
+	assert.Contains(t, get("/nope?theme=raw"), "There is nothing here.", "the views of ThemeFS are not those of raw")
+	assert.Contains(t, get("/nope?theme=sidebar"), `<link href="/static/themes/sidebar/css/common.css">Lost in the sidebar`)
+
+	_, e := (&Asteroid{FS: a.FS, ThemeFS: fstest.MapFS{"views": {Mode: fs.ModeDir}}}).NewHandler()
+	assert.NoError(t, e, "an empty views/ changes nothing")
+}
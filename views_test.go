@@ -0,0 +1,29 @@
+package gnAsteroid
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveReloadScript(t *testing.T) {
+	cfg := configWith(mockChain(t))
+	cfg.LiveReload = "/.livereload"
+	app := MakeGnowebAppWithOptions(slog.Default(), &cfg, Options{})
+	for _, route := range []string{"/r/demo/users", "/r/demo/users?help", "/r/demo/users/", "/r/demo/users/users.gno", "/p/demo/avl/"} {
+		response := serveRoute(t, app.Router, route, nil)
+		assert.Equal(t, http.StatusOK, response.Code, route)
+		assert.Contains(t, response.Body.String(), `src="/static/js/livereload.js" data-url="/.livereload"`, route)
+	}
+
+	// under the prefix of the asteroid
+	asteroid := NewAsteroid(fstest.MapFS{"index.md": {Data: []byte("# Home")}}, "Wiki")
+	asteroid.Config = &cfg
+	asteroid.Prefix = "/wiki"
+	response := serveRoute(t, asteroid.Handler(), "/wiki/", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `src="/wiki/static/js/livereload.js" data-url="/wiki/.livereload"`)
+}